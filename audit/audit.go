@@ -0,0 +1,199 @@
+// Package audit provides an http.RoundTripper which records an audit trail of
+// the requests made to the reCAPTCHA verification endpoint, with the secret
+// redacted and the token hashed. If the Client renames the secret or token
+// field via recaptcha.SetFieldNames, pass the same names to SetSecretFieldName
+// and SetResponseFieldName. Install it on a Client via the
+// recaptcha.SetHTTPClient option:
+//
+//	client := recaptcha.NewClient("my_secret",
+//		recaptcha.SetHTTPClient(&http.Client{
+//			Transport: audit.NewRoundTripper(nil, func(record audit.Record) {
+//				log.Printf("reCAPTCHA verification: %+v", record)
+//			}),
+//		}),
+//	)
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nicheinc/recaptcha"
+)
+
+// Redacted is the value that replaces the secret in audit records.
+const Redacted = "REDACTED"
+
+// Record is an audit record of a single request to the reCAPTCHA verification
+// endpoint.
+type Record struct {
+	// Time at which the request was made.
+	Time time.Time
+	// Duration of the round trip.
+	Duration time.Duration
+	// URL of the verification endpoint.
+	URL string
+	// Form values sent in the request body, with the secret redacted, and the
+	// token replaced by the hex-encoded SHA-256 hash of its value.
+	Form url.Values
+	// StatusCode of the response, if one was received.
+	StatusCode int
+	// Response decoded from the response body, if one was received and could
+	// be decoded. It is not decoded if the body is larger than the maximum
+	// size (see SetMaxBytes).
+	Response recaptcha.Response
+	// Err is the error returned by the underlying transport, if any.
+	Err error
+}
+
+// RoundTripper is an http.RoundTripper which wraps another http.RoundTripper,
+// and passes a Record of each request to a callback. Created with
+// NewRoundTripper.
+type RoundTripper struct {
+	next          http.RoundTripper
+	record        func(Record)
+	secretField   string
+	responseField string
+	maxBytes      int64
+}
+
+var _ http.RoundTripper = &RoundTripper{}
+
+// Option is an option for creating a RoundTripper.
+type Option func(t *RoundTripper)
+
+// SetSecretFieldName is an option for creating a RoundTripper which redacts the
+// form field with the provided name, rather than "secret". It must be provided
+// whenever the Client's secret field is renamed via recaptcha.SetFieldNames,
+// otherwise the secret is recorded in the clear.
+func SetSecretFieldName(name string) Option {
+	return func(t *RoundTripper) {
+		t.secretField = name
+	}
+}
+
+// SetResponseFieldName is an option for creating a RoundTripper which hashes
+// the token in the form field with the provided name, rather than "response".
+// It must be provided whenever the Client's token field is renamed via
+// recaptcha.SetFieldNames, otherwise the token is recorded in the clear.
+func SetResponseFieldName(name string) Option {
+	return func(t *RoundTripper) {
+		t.responseField = name
+	}
+}
+
+// SetMaxBytes is an option for creating a RoundTripper which reads at most the
+// provided number of bytes of each response body in order to decode the
+// Record's Response. The rest of the body is left unread, and is streamed to
+// the Client as usual, so that the Client's own limit (see recaptcha.SetLimits)
+// still applies. If not provided, recaptcha.DefaultLimits.MaxBytes is used.
+func SetMaxBytes(n int64) Option {
+	return func(t *RoundTripper) {
+		t.maxBytes = n
+	}
+}
+
+// NewRoundTripper creates a RoundTripper which makes requests via next, and
+// passes a Record of each request to the record callback. If next is nil,
+// http.DefaultTransport is used. The callback is invoked synchronously, and
+// must be safe for concurrent use.
+func NewRoundTripper(next http.RoundTripper, record func(Record), opts ...Option) *RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &RoundTripper{
+		next:          next,
+		record:        record,
+		secretField:   "secret",
+		responseField: "response",
+		maxBytes:      recaptcha.DefaultLimits.MaxBytes,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	record := Record{
+		Time: time.Now(),
+		URL:  req.URL.String(),
+	}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			if _, ok := form[t.secretField]; ok {
+				form.Set(t.secretField, Redacted)
+			}
+			if token, ok := form[t.responseField]; ok {
+				form.Set(t.responseField, hashToken(token[0]))
+			}
+			record.Form = form
+		}
+
+		// RoundTrippers must not modify the request, so make a shallow copy
+		// with a fresh body.
+		clone := *req
+		clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req = &clone
+	}
+
+	res, err := t.next.RoundTrip(req)
+	record.Duration = time.Since(record.Time)
+	if err != nil {
+		record.Err = err
+		t.record(record)
+		return nil, err
+	}
+	record.StatusCode = res.StatusCode
+
+	// Read no more of the body than needed, and pass the rest through
+	// untouched, so that the Client's own size limit still applies.
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, t.maxBytes+1))
+	if err != nil {
+		res.Body.Close()
+		record.Err = err
+		t.record(record)
+		return nil, err
+	}
+	res.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), res.Body),
+		Closer: res.Body,
+	}
+
+	if int64(len(body)) <= t.maxBytes {
+		var response recaptcha.Response
+		if err := json.Unmarshal(body, &response); err == nil {
+			record.Response = response
+		}
+	}
+
+	t.record(record)
+	return res, nil
+}
+
+// readCloser combines an io.Reader with the io.Closer of another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// hashToken returns the hex-encoded SHA-256 hash of the token, as in the
+// events written via recaptcha.SetEventWriter.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nicheinc/recaptcha"
+	"golang.org/x/xerrors"
+)
+
+type roundTripperMock struct {
+	roundTripStub func(req *http.Request) (*http.Response, error)
+}
+
+func (m *roundTripperMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.roundTripStub(req)
+}
+
+// tokenHash is the hex-encoded SHA-256 hash of "token".
+const tokenHash = "3c469e9d6c5875d37a43f353d4f88e61fcf812c66eee3457465a40b0da4153e0"
+
+func TestRoundTripper(t *testing.T) {
+	testCases := []struct {
+		name      string
+		transport http.RoundTripper
+		form      url.Values
+		status    int
+		score     float64
+		err       error
+	}{
+		{
+			name: "Success",
+			transport: &roundTripperMock{
+				roundTripStub: func(req *http.Request) (*http.Response, error) {
+					if err := req.ParseForm(); err != nil {
+						return nil, err
+					}
+					if secret := req.PostForm.Get("secret"); secret != "my_secret" {
+						return nil, errors.New("secret not sent: " + secret)
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"success": true, "score": 0.7}`)),
+					}, nil
+				},
+			},
+			form: url.Values{
+				"secret":   {Redacted},
+				"response": {tokenHash},
+				"remoteip": {"192.169.0.1"},
+			},
+			status: http.StatusOK,
+			score:  .7,
+		},
+		{
+			name: "Error",
+			transport: &roundTripperMock{
+				roundTripStub: func(req *http.Request) (*http.Response, error) {
+					return nil, errors.New("AAHHH")
+				},
+			},
+			form: url.Values{
+				"secret":   {Redacted},
+				"response": {tokenHash},
+				"remoteip": {"192.169.0.1"},
+			},
+			err: errors.New("AAHHH"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var records []Record
+			client := recaptcha.NewClient("my_secret",
+				recaptcha.SetHTTPClient(&http.Client{
+					Transport: NewRoundTripper(testCase.transport, func(record Record) {
+						records = append(records, record)
+					}),
+				}),
+			)
+			response, _ := client.Fetch(context.Background(), "token", "192.169.0.1")
+
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			record := records[0]
+			if !reflect.DeepEqual(testCase.form, record.Form) {
+				t.Errorf("Expected form:\n%#v\nActual:\n%#v\n", testCase.form, record.Form)
+			}
+			if record.URL != recaptcha.DefaultURL {
+				t.Errorf("Expected URL %s, got %s", recaptcha.DefaultURL, record.URL)
+			}
+			if record.StatusCode != testCase.status {
+				t.Errorf("Expected status code %d, got %d", testCase.status, record.StatusCode)
+			}
+			if record.Response.Score != testCase.score {
+				t.Errorf("Expected score %f, got %f", testCase.score, record.Response.Score)
+			}
+			if response.Score != testCase.score {
+				t.Errorf("Expected fetched score %f, got %f", testCase.score, response.Score)
+			}
+			if testCase.err == nil && record.Err != nil {
+				t.Errorf("Unexpected error: %s", record.Err)
+			} else if testCase.err != nil && (record.Err == nil || record.Err.Error() != testCase.err.Error()) {
+				t.Errorf("Expected error %s, got %v", testCase.err, record.Err)
+			}
+		})
+	}
+}
+
+func TestRoundTripperSecretFieldName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		expected url.Values
+	}{
+		{
+			name: "Renamed",
+			opts: []Option{SetSecretFieldName("key")},
+			expected: url.Values{
+				"key":      {Redacted},
+				"response": {tokenHash},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var records []Record
+			transport := &roundTripperMock{
+				roundTripStub: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+					}, nil
+				},
+			}
+			client := recaptcha.NewClient("my_secret",
+				recaptcha.SetFieldNames("key", "", ""),
+				recaptcha.SetHTTPClient(&http.Client{
+					Transport: NewRoundTripper(transport, func(record Record) {
+						records = append(records, record)
+					}, testCase.opts...),
+				}),
+			)
+			if _, err := client.Fetch(context.Background(), "token", ""); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			if !reflect.DeepEqual(testCase.expected, records[0].Form) {
+				t.Errorf("Expected form:\n%#v\nActual:\n%#v\n", testCase.expected, records[0].Form)
+			}
+		})
+	}
+}
+
+func TestRoundTripperResponseFieldName(t *testing.T) {
+	var records []Record
+	transport := &roundTripperMock{
+		roundTripStub: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+			}, nil
+		},
+	}
+	client := recaptcha.NewClient("my_secret",
+		recaptcha.SetFieldNames("", "token", ""),
+		recaptcha.SetHTTPClient(&http.Client{
+			Transport: NewRoundTripper(transport, func(record Record) {
+				records = append(records, record)
+			}, SetResponseFieldName("token")),
+		}),
+	)
+	if _, err := client.Fetch(context.Background(), "token", ""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := url.Values{
+		"secret": {Redacted},
+		"token":  {tokenHash},
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	if !reflect.DeepEqual(expected, records[0].Form) {
+		t.Errorf("Expected form:\n%#v\nActual:\n%#v\n", expected, records[0].Form)
+	}
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestRoundTripperMaxBytes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		clientLimit   int64
+		auditLimit    int64
+		expectedErr   bool
+		expectedScore float64
+	}{
+		{
+			name:          "WithinLimits",
+			clientLimit:   1 << 10,
+			auditLimit:    1 << 10,
+			expectedScore: .7,
+		},
+		{
+			// The client's limit still applies
+			name:        "ClientLimit",
+			clientLimit: 16,
+			auditLimit:  8,
+			expectedErr: true,
+		},
+		{
+			// The body is still passed through in full, but too large to
+			// decode for the record
+			name:          "AuditLimit",
+			clientLimit:   1 << 10,
+			auditLimit:    8,
+			expectedScore: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				records []Record
+				read    []int
+				body    *countingReader
+			)
+			transport := &roundTripperMock{
+				roundTripStub: func(req *http.Request) (*http.Response, error) {
+					body = &countingReader{
+						r: strings.NewReader(`{"success": true, "score": 0.7}` + strings.Repeat(" ", 1<<9)),
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(body),
+					}, nil
+				},
+			}
+			client := recaptcha.NewClient("my_secret",
+				recaptcha.SetLimits(recaptcha.Limits{MaxBytes: testCase.clientLimit}),
+				recaptcha.SetHTTPClient(&http.Client{
+					Transport: NewRoundTripper(transport, func(record Record) {
+						records = append(records, record)
+						read = append(read, body.n)
+					}, SetMaxBytes(testCase.auditLimit)),
+				}),
+			)
+			response, err := client.Fetch(context.Background(), "token", "")
+
+			var tooLarge *recaptcha.ResponseTooLargeError
+			if testCase.expectedErr != xerrors.As(err, &tooLarge) {
+				t.Errorf("Expected *ResponseTooLargeError: %t, got %v", testCase.expectedErr, err)
+			}
+			if !testCase.expectedErr && response.Score != .7 {
+				t.Errorf("Expected fetched score %f, got %f", .7, response.Score)
+			}
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			// The auditor itself reads no more than its limit, plus one byte
+			if maxRead := int(testCase.auditLimit) + 1; read[0] > maxRead {
+				t.Errorf("Expected at most %d bytes read by the auditor, got %d", maxRead, read[0])
+			}
+			if records[0].Response.Score != testCase.expectedScore {
+				t.Errorf("Expected recorded score %f, got %f", testCase.expectedScore, records[0].Response.Score)
+			}
+		})
+	}
+}
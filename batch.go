@@ -0,0 +1,128 @@
+package recaptcha
+
+import (
+	"context"
+	"sync"
+)
+
+// FetchRequest represents the parameters of a single Fetch call, as consumed by
+// FetchStream.
+type FetchRequest struct {
+	Token  string
+	UserIP string
+}
+
+// FetchResult represents the result of a single Fetch call, as produced by
+// FetchStream.
+type FetchResult struct {
+	Request  FetchRequest
+	Response Response
+	Err      error
+}
+
+// FetchStream reads requests from the requests channel, fetches the
+// corresponding responses using the provided Client, with at most concurrency
+// calls to Fetch in flight at once, and writes the results to the results
+// channel (in no particular order). It returns once the requests channel has
+// been closed and all results have been written, or the context has been
+// cancelled, in which case the context's error is returned. The results
+// channel is closed before FetchStream returns.
+func FetchStream(ctx context.Context, client Client, concurrency int, requests <-chan FetchRequest, results chan<- FetchResult) error {
+	defer close(results)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var request FetchRequest
+				select {
+				case <-ctx.Done():
+					return
+				case r, ok := <-requests:
+					if !ok {
+						return
+					}
+					request = r
+				}
+
+				response, err := client.Fetch(ctx, request.Token, request.UserIP)
+				select {
+				case <-ctx.Done():
+					return
+				case results <- FetchResult{
+					Request:  request,
+					Response: response,
+					Err:      err,
+				}:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// FetchBatch fetches the responses for each of the provided requests using the
+// provided Client, with at most concurrency calls to Fetch in flight at once,
+// and returns the results in the same order as the requests. If the context is
+// cancelled partway through, FetchBatch stops starting new calls and returns
+// the results gathered so far, along with the context's error. The returned
+// slice always has one result per request: those whose calls were never
+// started have the context's error as their Err (e.g. context.Canceled).
+func FetchBatch(ctx context.Context, client Client, concurrency int, requests []FetchRequest) ([]FetchResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]FetchResult, len(requests))
+	started := make([]bool, len(requests))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					continue
+				}
+				started[i] = true
+				response, err := client.Fetch(ctx, requests[i].Token, requests[i].UserIP)
+				results[i] = FetchResult{
+					Request:  requests[i],
+					Response: response,
+					Err:      err,
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range requests {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	err := ctx.Err()
+	for i := range results {
+		if !started[i] {
+			results[i] = FetchResult{
+				Request: requests[i],
+				Err:     err,
+			}
+		}
+	}
+	return results, err
+}
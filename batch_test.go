@@ -0,0 +1,200 @@
+package recaptcha
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchStream(t *testing.T) {
+	var inFlight, maxInFlight int32
+	client := &Mock{
+		FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			if token == "error" {
+				return Response{}, errors.New("AAHHH")
+			}
+			return Response{
+				Success: true,
+				Action:  token,
+			}, nil
+		},
+	}
+
+	requests := make(chan FetchRequest)
+	results := make(chan FetchResult)
+	done := make(chan error)
+	go func() {
+		done <- FetchStream(context.Background(), client, 3, requests, results)
+	}()
+	go func() {
+		for i := 0; i < 20; i++ {
+			requests <- FetchRequest{Token: strconv.Itoa(i)}
+		}
+		requests <- FetchRequest{Token: "error"}
+		close(requests)
+	}()
+
+	var actions []string
+	var errs int
+	for result := range results {
+		if result.Err != nil {
+			errs++
+			continue
+		}
+		if result.Response.Action != result.Request.Token {
+			t.Errorf("Result for token %s has action %s", result.Request.Token, result.Response.Action)
+		}
+		actions = append(actions, result.Response.Action)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		expected = append(expected, strconv.Itoa(i))
+	}
+	sort.Strings(expected)
+	sort.Strings(actions)
+	if !reflect.DeepEqual(expected, actions) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actions)
+	}
+	if errs != 1 {
+		t.Errorf("Expected 1 error, got %d", errs)
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 3 {
+		t.Errorf("Expected at most 3 concurrent fetches, got %d", max)
+	}
+}
+
+func TestFetchStreamCancel(t *testing.T) {
+	client := &Mock{
+		FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+			return Response{Success: true}, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := make(chan FetchRequest)
+	results := make(chan FetchResult)
+	done := make(chan error)
+	go func() {
+		done <- FetchStream(ctx, client, 2, requests, results)
+	}()
+
+	requests <- FetchRequest{Token: "token"}
+	<-results
+	cancel()
+
+	// Results channel is closed without the requests channel being closed
+	for range results {
+	}
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFetchBatch(t *testing.T) {
+	client := &Mock{
+		FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+			if token == "error" {
+				return Response{}, errors.New("AAHHH")
+			}
+			return Response{
+				Success: true,
+				Action:  token,
+			}, nil
+		},
+	}
+
+	requests := []FetchRequest{
+		{Token: "a", UserIP: "192.168.0.1"},
+		{Token: "error"},
+		{Token: "b"},
+		{Token: "c"},
+	}
+	expected := []FetchResult{
+		{
+			Request:  requests[0],
+			Response: Response{Success: true, Action: "a"},
+		},
+		{
+			Request: requests[1],
+			Err:     errors.New("AAHHH"),
+		},
+		{
+			Request:  requests[2],
+			Response: Response{Success: true, Action: "b"},
+		},
+		{
+			Request:  requests[3],
+			Response: Response{Success: true, Action: "c"},
+		},
+	}
+
+	actual, err := FetchBatch(context.Background(), client, 2, requests)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+	}
+}
+
+func TestFetchBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &Mock{
+		FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+			// Cancel the context partway through the batch.
+			if token == "2" {
+				cancel()
+			}
+			return Response{
+				Success: true,
+				Action:  token,
+			}, nil
+		},
+	}
+
+	var requests []FetchRequest
+	for i := 0; i < 10; i++ {
+		requests = append(requests, FetchRequest{Token: strconv.Itoa(i)})
+	}
+
+	actual, err := FetchBatch(ctx, client, 1, requests)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(actual) != len(requests) {
+		t.Fatalf("Expected %d results, got %d", len(requests), len(actual))
+	}
+	for i, result := range actual {
+		if result.Request != requests[i] {
+			t.Errorf("Result %d has request %#v, expected %#v", i, result.Request, requests[i])
+		}
+		switch {
+		case i <= 2:
+			if result.Err != nil || result.Response.Action != requests[i].Token {
+				t.Errorf("Expected successful result %d, got %#v", i, result)
+			}
+		default:
+			if result.Err != context.Canceled {
+				t.Errorf("Expected result %d to have context.Canceled, got %#v", i, result)
+			}
+		}
+	}
+}
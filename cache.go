@@ -0,0 +1,124 @@
+package recaptcha
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is a minimal key-value store with expiration, used to share state
+// (e.g. previously seen tokens) between calls to Fetch. An in-memory
+// implementation is provided by NewMemoryCache; distributed deployments can
+// supply their own implementation backed by e.g. Redis or memcached.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key, and whether it was found. Values
+	// whose TTL has elapsed must not be returned.
+	Get(key string) ([]byte, bool)
+	// Set stores val under key for the duration of ttl. A non-positive ttl
+	// means the value does not expire.
+	Set(key string, val []byte, ttl time.Duration)
+}
+
+// AddCache is an optional interface which a Cache can implement to store a
+// value only if its key is absent, atomically (e.g. via the Redis SET command
+// with the NX option). Where it is implemented, SetActionBinding relies on it
+// to reject concurrent first uses of a token with different actions. The
+// cache returned by NewMemoryCache implements it.
+type AddCache interface {
+	Cache
+	// Add stores val under key for the duration of ttl, unless an unexpired
+	// value is already stored under key. It returns the value stored under
+	// key once it returns, and whether it was val.
+	Add(key string, val []byte, ttl time.Duration) ([]byte, bool)
+}
+
+// sweepInterval is the minimum interval between sweeps of expired entries
+// from a memoryCache.
+const sweepInterval = time.Minute
+
+// memoryCache is an in-memory implementation of Cache.
+type memoryCache struct {
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	nextSweep time.Time
+}
+
+// cacheEntry is a value stored in a memoryCache, along with its expiration
+// time. A zero expiration time means the value does not expire.
+type cacheEntry struct {
+	val     []byte
+	expires time.Time
+}
+
+func (e cacheEntry) expired(t time.Time) bool {
+	return !e.expires.IsZero() && !t.Before(e.expires)
+}
+
+var _ AddCache = &memoryCache{}
+
+// NewMemoryCache returns a Cache which stores values in memory. Expired values
+// are removed lazily, when they are looked up or during periodic sweeps when
+// new values are stored.
+func NewMemoryCache() Cache {
+	return &memoryCache{
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// Get returns a copy of the value stored under key, if present and not
+// expired.
+func (c *memoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.expired(now()) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return append([]byte(nil), entry.val...), true
+}
+
+// Set stores a copy of val under key for the duration of ttl.
+func (c *memoryCache) Set(key string, val []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, val, ttl)
+}
+
+// Add stores a copy of val under key for the duration of ttl, unless an
+// unexpired value is already stored under key, in which case a copy of that
+// value is returned instead.
+func (c *memoryCache) Add(key string, val []byte, ttl time.Duration) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok && !entry.expired(now()) {
+		return append([]byte(nil), entry.val...), false
+	}
+	c.set(key, val, ttl)
+	return append([]byte(nil), val...), true
+}
+
+// set stores a copy of val under key for the duration of ttl, sweeping
+// expired entries if due. c.mu must be held.
+func (c *memoryCache) set(key string, val []byte, ttl time.Duration) {
+	current := now()
+	if !current.Before(c.nextSweep) {
+		for k, entry := range c.entries {
+			if entry.expired(current) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = current.Add(sweepInterval)
+	}
+
+	entry := cacheEntry{
+		val: append([]byte(nil), val...),
+	}
+	if ttl > 0 {
+		entry.expires = current.Add(ttl)
+	}
+	c.entries[key] = entry
+}
@@ -0,0 +1,109 @@
+package recaptcha
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	cache := NewMemoryCache()
+	if val, ok := cache.Get("missing"); ok {
+		t.Errorf("Expected missing key, got %q", val)
+	}
+
+	val := []byte("value")
+	cache.Set("key", val, time.Minute)
+	cache.Set("forever", []byte("forever"), 0)
+
+	// Modifying the stored slice must not affect the cached value.
+	val[0] = 'V'
+	if actual, ok := cache.Get("key"); !ok || !reflect.DeepEqual([]byte("value"), actual) {
+		t.Errorf("Expected:\n%q\nActual:\n%q (found: %t)\n", "value", actual, ok)
+	}
+
+	current = current.Add(time.Minute)
+	if actual, ok := cache.Get("key"); ok {
+		t.Errorf("Expected expired key, got %q", actual)
+	}
+	if actual, ok := cache.Get("forever"); !ok || !reflect.DeepEqual([]byte("forever"), actual) {
+		t.Errorf("Expected:\n%q\nActual:\n%q (found: %t)\n", "forever", actual, ok)
+	}
+
+	// Overwriting a key replaces its value and TTL.
+	cache.Set("forever", []byte("updated"), time.Second)
+	current = current.Add(time.Second)
+	if actual, ok := cache.Get("forever"); ok {
+		t.Errorf("Expected expired key, got %q", actual)
+	}
+}
+
+func TestMemoryCacheAdd(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	cache := NewMemoryCache().(AddCache)
+	steps := []struct {
+		val      string
+		elapsed  time.Duration
+		expected string
+		added    bool
+	}{
+		{val: "a", expected: "a", added: true},
+		{val: "b", expected: "a", added: false},
+		{val: "b", elapsed: time.Minute, expected: "b", added: true},
+	}
+	for i, step := range steps {
+		current = current.Add(step.elapsed)
+		actual, added := cache.Add("key", []byte(step.val), time.Minute)
+		if string(actual) != step.expected || added != step.added {
+			t.Errorf("Step %d: Expected %q (added: %t), got %q (added: %t)", i, step.expected, step.added, actual, added)
+		}
+	}
+}
+
+func TestMemoryCacheSweep(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	cache := NewMemoryCache().(*memoryCache)
+	cache.Set("a", []byte("a"), time.Second)
+	cache.Set("b", []byte("b"), time.Hour)
+
+	// Expired entries are only swept once the sweep interval has elapsed.
+	current = current.Add(2 * time.Second)
+	cache.Set("c", []byte("c"), time.Second)
+	if len(cache.entries) != 3 {
+		t.Errorf("Expected 3 entries, got %d", len(cache.entries))
+	}
+
+	current = current.Add(sweepInterval)
+	cache.Set("d", []byte("d"), time.Second)
+	if _, ok := cache.entries["a"]; ok {
+		t.Errorf("Expected entry %q to be swept", "a")
+	}
+	if _, ok := cache.entries["c"]; ok {
+		t.Errorf("Expected entry %q to be swept", "c")
+	}
+	if len(cache.entries) != 2 {
+		t.Errorf("Expected 2 entries, got %d", len(cache.entries))
+	}
+}
@@ -11,14 +11,25 @@
 package recaptcha
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"golang.org/x/net/publicsuffix"
 	"golang.org/x/xerrors"
 )
 
@@ -36,13 +47,30 @@ type HTTPClient interface {
 // receiving token verification responses. Created with NewClient.
 type Client interface {
 	Fetch(ctx context.Context, token, userIP string) (Response, error)
+	VerifyCached(ctx context.Context, token, userIP string, maxAge time.Duration, criteria ...Criterion) (Response, error)
+	With(opts ...Option) Client
+	Stats() Stats
+	Validate(ctx context.Context) error
 }
 
 // Concrete implementation of the Client interface. Created with NewClient.
 type client struct {
-	secret     string
-	url        string
-	httpClient HTTPClient
+	secrets        []string
+	url            string
+	fallbackURL    string
+	httpClient     HTTPClient
+	fieldNames     fieldNames
+	observer       Observer
+	omitRemoteIP   bool
+	requiredFields []string
+	lenient        bool
+	requireJSON    bool
+	actionBinding  Cache
+	responseCache  Cache
+	curlLogger     func(curl string)
+	eventWriter    *eventWriter
+	limits         Limits
+	stats          *stats
 }
 
 // Option represents a configuration option that can be applied when creating a
@@ -65,15 +93,242 @@ func SetURL(url string) Option {
 	}
 }
 
+// SetFallbackURL is an option for creating a Client which fails over to a
+// secondary verification endpoint (e.g. a mirror or self-hosted proxy) if the
+// primary endpoint is unavailable, i.e. if the request fails with a transport
+// error or a 5xx status code. Other failures, including a 404 status code
+// (which indicates a misconfigured URL) and a malformed response body, are
+// returned without failing over. The primary endpoint is always tried first.
+//
+// The fallback endpoint receives your secret key and the user's token, and
+// its responses are trusted exactly as much as those from the primary
+// endpoint, so it must be a service you control or trust to the same degree.
+// Note also that if the primary endpoint processed the token before failing,
+// the fallback may report it as a duplicate ("timeout-or-duplicate").
+func SetFallbackURL(url string) Option {
+	return func(c *client) {
+		c.fallbackURL = url
+	}
+}
+
+// fieldNames are the names of the form fields sent in requests to the
+// verification endpoint.
+type fieldNames struct {
+	secret   string
+	response string
+	remoteIP string
+}
+
+// defaultFieldNames are the form field names expected by Google's verification
+// endpoint.
+var defaultFieldNames = fieldNames{
+	secret:   "secret",
+	response: "response",
+	remoteIP: "remoteip",
+}
+
+// SetFieldNames is an option for creating a Client which uses custom form
+// field names for the secret, token, and user IP address sent to the
+// verification endpoint, e.g. when using SetURL to target a self-hosted
+// verification proxy with its own schema. Empty names are left unchanged. If
+// not provided, the Client will use Google's names ("secret", "response", and
+// "remoteip").
+func SetFieldNames(secret, response, remoteIP string) Option {
+	return func(c *client) {
+		if secret != "" {
+			c.fieldNames.secret = secret
+		}
+		if response != "" {
+			c.fieldNames.response = response
+		}
+		if remoteIP != "" {
+			c.fieldNames.remoteIP = remoteIP
+		}
+	}
+}
+
+// SetOmitRemoteIP is an option for creating a Client which never sends the
+// user's IP address to the reCAPTCHA verification endpoint, regardless of the
+// userIP passed to Fetch (e.g. for privacy reasons).
+func SetOmitRemoteIP() Option {
+	return func(c *client) {
+		c.omitRemoteIP = true
+	}
+}
+
+// SetRequiredFields is an option for creating a Client which ensures that the
+// provided fields (identified by their JSON names, i.e. "score", "action",
+// "challenge_ts", or "hostname") are present and non-zero in any successful
+// response returned by Fetch. This helps catch misconfigurations early (e.g. a
+// reCAPTCHA v2 secret being used for v3 tokens). Fetch returns
+// *MissingFieldError if a required field is missing.
+func SetRequiredFields(fields ...string) Option {
+	return func(c *client) {
+		c.requiredFields = fields
+	}
+}
+
+// SetLenientDecoding is an option for creating a Client which decodes each
+// field of the response independently, so that a single malformed field does
+// not prevent the others from being decoded. If any fields are malformed,
+// Fetch returns the partially decoded response along with a *DecodeError
+// listing the malformed fields. This is mainly useful for logging and
+// debugging upstream issues.
+func SetLenientDecoding() Option {
+	return func(c *client) {
+		c.lenient = true
+	}
+}
+
+// SetRequireJSONContentType is an option for creating a Client which ensures
+// that the verification endpoint's responses have a Content-Type of
+// "application/json" (with any parameters, e.g. a charset). A different
+// Content-Type typically indicates an error page served by a proxy or captive
+// portal in place of a genuine response. Fetch returns *UnexpectedResponseError
+// if the Content-Type is not JSON or is missing, so this option should not be
+// used with proxies which omit the header.
+func SetRequireJSONContentType() Option {
+	return func(c *client) {
+		c.requireJSON = true
+	}
+}
+
+// actionBindingTTL is how long SetActionBinding remembers the action a token
+// was first seen with. Tokens are only valid for 2 minutes after they are
+// issued, so there is no need to remember them for longer.
+const actionBindingTTL = 2 * time.Minute
+
+// SetActionBinding is an option for creating a Client which binds each token to
+// the actions the caller expects it to have been issued for, as stored in the
+// context passed to Fetch (or VerifyCached) by WithExpectedActions. The
+// expected actions are recorded in the provided Cache, keyed by a hash of the
+// token, the first time the token is presented, and a token which is later
+// presented with a different set of expected actions (e.g. a token issued on
+// the login page replayed against the checkout endpoint) is rejected before
+// the verification endpoint is called. In that case Fetch returns
+// *ActionBindingError. The order of the expected actions does not matter.
+// Tokens presented without expected actions are not bound. A shared Cache
+// (e.g. backed by Redis) extends the check across instances.
+//
+// The check is only atomic if the Cache implements AddCache, as the one
+// returned by NewMemoryCache does. Otherwise, it is best-effort: concurrent
+// first uses of a token with different actions may all be accepted.
+func SetActionBinding(cache Cache) Option {
+	return func(c *client) {
+		c.actionBinding = cache
+	}
+}
+
+// responseCacheTTL is how long responses are kept in the cache provided via
+// SetResponseCache. Tokens are only valid for 2 minutes after they are issued,
+// so there is no need to keep them for longer.
+const responseCacheTTL = 2 * time.Minute
+
+// SetResponseCache is an option for creating a Client which stores the
+// responses fetched by VerifyCached in the provided Cache, keyed by a hash of
+// the token, so that they can be reverified without calling the verification
+// endpoint again. If not provided, VerifyCached always calls Fetch.
+func SetResponseCache(cache Cache) Option {
+	return func(c *client) {
+		c.responseCache = cache
+	}
+}
+
+// SetCurlLogger is an option for creating a Client which renders each request
+// to the verification endpoint as an equivalent curl command, and passes it to
+// the provided function (e.g. for logging), so that failing verifications can
+// be reproduced by hand. The secret is replaced with "REDACTED".
+func SetCurlLogger(logger func(curl string)) Option {
+	return func(c *client) {
+		c.curlLogger = logger
+	}
+}
+
+// Limits are safety limits on requests to the verification endpoint, as
+// applied via the SetLimits option. Zero or negative values are replaced by the
+// corresponding values of DefaultLimits.
+type Limits struct {
+	// Timeout is the maximum duration of a call to Fetch, including any
+	// requests for additional secrets (see SetSecrets) or to the fallback
+	// endpoint (see SetFallbackURL). It applies in addition to any deadline of
+	// the context passed to Fetch, and to any timeout of the HTTPClient.
+	Timeout time.Duration
+	// MaxBytes is the maximum size of a response body. Fetch returns
+	// *ResponseTooLargeError for larger responses, without reading them in
+	// full.
+	MaxBytes int64
+}
+
+// DefaultLimits are the Limits used for any fields left unset in the Limits
+// passed to SetLimits. Genuine responses from the verification endpoint are
+// well under 1KB, so the maximum size leaves plenty of headroom.
+var DefaultLimits = Limits{
+	Timeout:  10 * time.Second,
+	MaxBytes: 64 << 10,
+}
+
+// SetLimits is an option for creating a Client which enforces the provided
+// safety limits on each call to Fetch, so that hardening can be configured in
+// one place. Any fields which are zero or negative are replaced by the
+// corresponding values of DefaultLimits, so SetLimits(Limits{}) applies the
+// defaults. If not provided, the Client enforces no limits of its own.
+func SetLimits(limits Limits) Option {
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultLimits.Timeout
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = DefaultLimits.MaxBytes
+	}
+	return func(c *client) {
+		c.limits = limits
+	}
+}
+
+// Observer is a callback which is invoked with the result of each call to
+// Fetch, e.g. for the purpose of recording metrics. The context passed to
+// Fetch is provided, so that request-scoped values can be retrieved from it.
+// Observers must be safe for concurrent use.
+type Observer func(ctx context.Context, r Response, err error)
+
+// SetObserver is an option for creating a Client which invokes the provided
+// Observer with the result of each call to Fetch. The Observer is invoked
+// synchronously, before Fetch returns.
+func SetObserver(observer Observer) Option {
+	return func(c *client) {
+		c.observer = observer
+	}
+}
+
+// maxSecrets is the maximum number of secrets that will be tried by Fetch.
+const maxSecrets = 3
+
+// SetSecrets is an option for creating a Client that tries multiple secrets
+// (e.g. while rotating keys), overriding the secret provided to NewClient.
+// Fetch tries each secret in order until a response with a "success" field of
+// true is returned, or until all secrets have been tried. At most 3 secrets
+// are used; any additional secrets are ignored.
+func SetSecrets(secrets ...string) Option {
+	return func(c *client) {
+		if len(secrets) > maxSecrets {
+			secrets = secrets[:maxSecrets]
+		}
+		if len(secrets) > 0 {
+			c.secrets = secrets
+		}
+	}
+}
+
 // NewClient creates an instance of Client, which is thread-safe and should be
 // reused instead of created as needed. You must provided your website's secret
 // key, which is shared between your site and reCAPTCHA. Additional
 // configuration options may also be provided (e.g. SetHTTPClient, SetURL).
 func NewClient(secret string, opts ...Option) Client {
 	c := &client{
-		secret:     secret,
+		secrets:    []string{secret},
 		url:        DefaultURL,
 		httpClient: http.DefaultClient,
+		fieldNames: defaultFieldNames,
+		stats:      &stats{},
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -81,90 +336,1112 @@ func NewClient(secret string, opts ...Option) Client {
 	return c
 }
 
+// With returns a copy of the Client with the provided options applied. The
+// original Client is unaffected, but the copy shares its HTTPClient (unless
+// overridden), making it cheap to derive variants of a base Client (e.g. with
+// a different secret or URL).
+func (c *client) With(opts ...Option) Client {
+	clone := *c
+	clone.stats = &stats{}
+	for _, opt := range opts {
+		opt(&clone)
+	}
+	return &clone
+}
+
+// Stats returns counts of the results of Fetch calls made by the Client since
+// it was created.
+func (c *client) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// validateToken is the placeholder token sent to the verification endpoint by
+// Validate. It is never a valid token, so the endpoint's response reveals only
+// whether the secret is valid.
+const validateToken = "recaptcha-validate"
+
+// Validate checks that the Client's secrets are accepted by the verification
+// endpoint, e.g. as a self-check at startup, by making a request with a
+// placeholder token for each secret. A response rejecting the token (e.g. with
+// the "invalid-input-response" error code) indicates that the secret is fine.
+// Returns *SecretConfigError, wrapped with the position of the secret, if the
+// endpoint reports that a secret is missing or invalid, or another error if a
+// request fails. The requests are not counted in Stats or passed to the
+// Observer.
+func (c *client) Validate(ctx context.Context) error {
+	for i, secret := range c.secrets {
+		response, err := c.fetch(ctx, secret, validateToken, "")
+		if err != nil {
+			return xerrors.Errorf("error validating secret %d: %w", i+1, err)
+		}
+		if err := NoSecretErrors()(&response); err != nil {
+			return xerrors.Errorf("error validating secret %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
 // Fetch makes a request to the reCAPTCHA verification endpoint using the
 // provided token and optional userIP (which can be omitted from the request by
 // providing an empty string), and returns the response. To check whether the
-// token was actually valid, use the response's Verify method.
+// token was actually valid, use the response's Verify method. If multiple
+// secrets were provided via SetSecrets, the response for the last secret tried
+// is returned. If the token is empty, no request is made, and an
+// unsuccessful response with the "missing-input-response" error code is
+// returned.
 func (c *client) Fetch(ctx context.Context, token, userIP string) (Response, error) {
+	response, err := c.fetchSecrets(ctx, token, userIP)
+	c.stats.record(response, err)
+	if c.eventWriter != nil {
+		c.eventWriter.write(ctx, token, response, err)
+	}
+	if c.observer != nil {
+		c.observer(ctx, response, err)
+	}
+	return response, err
+}
+
+// cachedResponse is the representation of a Response stored in the cache
+// provided via SetResponseCache. The unexported and non-JSON fields of the
+// Response are stored alongside it, so that it can be restored exactly.
+type cachedResponse struct {
+	FetchedAt  time.Time                  `json:"fetched_at"`
+	Response   Response                   `json:"response"`
+	Present    fieldSet                   `json:"present"`
+	ServerDate time.Time                  `json:"server_date"`
+	Extra      map[string]json.RawMessage `json:"extra,omitempty"`
+}
+
+// VerifyCached fetches the response for the provided token, as in Fetch, and
+// verifies it using the provided criteria, as in Verify. If the SetResponseCache
+// option was provided and a response for the token was fetched no more than
+// maxAge ago, the cached response is reverified instead of calling the
+// verification endpoint again. If the SetActionBinding option was provided,
+// the token's binding is checked even on a cache hit. Cache hits are not counted in Stats or passed to
+// the Observer.
+//
+// Responses are cached per token, secrets, and URL, so a cache shared with
+// clients derived via With (e.g. with another tenant's secrets) never returns a
+// response which was not fetched with the client's own secrets.
+//
+// Tokens are single-use: the verification endpoint rejects a token which has
+// already been verified. VerifyCached exists so that several components
+// handling the same request (e.g. multiple middlewares) can each verify its
+// token. It must not be used to accept the same token across separate
+// requests, which would defeat replay protection, so keep maxAge short.
+func (c *client) VerifyCached(ctx context.Context, token, userIP string, maxAge time.Duration, criteria ...Criterion) (Response, error) {
+	// Check the binding up front, since cache hits do not go through Fetch
+	if c.actionBinding != nil {
+		if err := c.bindAction(token, ExpectedActions(ctx)); err != nil {
+			return Response{}, err
+		}
+	}
+
+	key := c.responseCacheKey(token)
+	if c.responseCache != nil {
+		if val, ok := c.responseCache.Get(key); ok {
+			var cached cachedResponse
+			if err := json.Unmarshal(val, &cached); err == nil && now().Sub(cached.FetchedAt) <= maxAge {
+				response := cached.Response
+				response.present = cached.Present
+				response.serverDate = cached.ServerDate
+				response.Extra = cached.Extra
+				return response, response.Verify(criteria...)
+			}
+		}
+	}
+
+	fetchedAt := now()
+	response, err := c.Fetch(ctx, token, userIP)
+	if err != nil {
+		return response, err
+	}
+	if c.responseCache != nil {
+		val, err := json.Marshal(cachedResponse{
+			FetchedAt:  fetchedAt,
+			Response:   response,
+			Present:    response.present,
+			ServerDate: response.serverDate,
+			Extra:      response.Extra,
+		})
+		if err == nil {
+			c.responseCache.Set(key, val, responseCacheTTL)
+		}
+	}
+	return response, response.Verify(criteria...)
+}
+
+// responseCacheKey returns the key under which the response for the token is
+// stored in the response cache. Since the cache may be shared with clients
+// derived via With, the key includes a hash of the secrets and URLs, so that a
+// response fetched by a client with different configuration (e.g. another
+// tenant's secret) is never returned.
+func (c *client) responseCacheKey(token string) string {
+	h := sha256.New()
+	for _, secret := range c.secrets {
+		h.Write([]byte(secret))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(c.url))
+	h.Write([]byte{0})
+	h.Write([]byte(c.fallbackURL))
+	return "recaptcha:response:" + hex.EncodeToString(h.Sum(nil)) + ":" + hashToken(token)
+}
+
+// fetchSecrets makes requests to the reCAPTCHA verification endpoint using
+// each secret in turn, until one of them is successful.
+func (c *client) fetchSecrets(ctx context.Context, token, userIP string) (Response, error) {
+	// The verification endpoint rejects empty tokens, so save the round trip
+	// by responding as it would.
+	if token == "" {
+		return Response{
+			Success:    false,
+			ErrorCodes: []string{"missing-input-response"},
+		}, nil
+	}
+
+	if c.actionBinding != nil {
+		if err := c.bindAction(token, ExpectedActions(ctx)); err != nil {
+			return Response{}, err
+		}
+	}
+
+	if c.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.limits.Timeout)
+		defer cancel()
+	}
+
+	var response Response
+	for i, secret := range c.secrets {
+		if i > 0 {
+			if err := ctx.Err(); err != nil {
+				return Response{}, xerrors.Errorf("error trying secret %d: %w", i+1, err)
+			}
+		}
+
+		var err error
+		response, err = c.fetch(ctx, secret, token, userIP)
+		if err != nil {
+			return response, err
+		}
+		if response.Success {
+			break
+		}
+	}
+
+	if response.Success {
+		for _, field := range c.requiredFields {
+			isZero, ok := fieldIsZero[field]
+			if !ok {
+				return Response{}, xerrors.Errorf("unknown required field: %q", field)
+			}
+			if isZero(&response) {
+				return Response{}, &MissingFieldError{
+					Field: field,
+				}
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash of the token, which identifies
+// it in caches and logs without revealing it.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bindAction records the expected actions associated with the token in the
+// client's action binding cache, or, if the token has been seen before,
+// ensures that it was seen with the same expected actions, regardless of
+// their order. Tokens without expected actions are not bound.
+func (c *client) bindAction(token string, actions []string) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	key := "recaptcha:action:" + hashToken(token)
+	expected := []byte(canonicalActions(actions))
+
+	var bound []byte
+	if cache, ok := c.actionBinding.(AddCache); ok {
+		bound, _ = cache.Add(key, expected, actionBindingTTL)
+	} else if val, ok := c.actionBinding.Get(key); ok {
+		bound = val
+	} else {
+		c.actionBinding.Set(key, expected, actionBindingTTL)
+		bound = expected
+	}
+
+	if !bytes.Equal(bound, expected) {
+		return &ActionBindingError{
+			BoundAction: string(bound),
+			Action:      string(expected),
+		}
+	}
+	return nil
+}
+
+// canonicalActions returns the provided actions, sorted and deduplicated, and
+// joined with commas, so that sets of actions can be compared.
+func canonicalActions(actions []string) string {
+	sorted := make([]string, len(actions))
+	copy(sorted, actions)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, action := range sorted {
+		if i == 0 || action != sorted[i-1] {
+			unique = append(unique, action)
+		}
+	}
+	return strings.Join(unique, ",")
+}
+
+// fieldIsZero maps the JSON name of each field that can be provided to
+// SetRequiredFields to a function that checks whether it is missing.
+var fieldIsZero = map[string]func(r *Response) bool{
+	"score": func(r *Response) bool {
+		return !r.HasScore()
+	},
+	"action": func(r *Response) bool {
+		return r.Action == ""
+	},
+	"challenge_ts": func(r *Response) bool {
+		return r.ChallengeTs.IsZero()
+	},
+	"hostname": func(r *Response) bool {
+		return r.Hostname == ""
+	},
+}
+
+// fetch makes a request to the reCAPTCHA verification endpoint using a single
+// secret, failing over to the fallback endpoint provided via SetFallbackURL
+// (if any) if the primary endpoint is unavailable.
+func (c *client) fetch(ctx context.Context, secret, token, userIP string) (Response, error) {
+	response, unavailable, err := c.fetchURL(ctx, c.url, secret, token, userIP)
+	if unavailable && c.fallbackURL != "" && ctx.Err() == nil {
+		response, _, err = c.fetchURL(ctx, c.fallbackURL, secret, token, userIP)
+	}
+	return response, err
+}
+
+// fetchURL makes a request to the verification endpoint at the provided URL.
+// If the request fails, it also reports whether the endpoint appears to be
+// unavailable (i.e. whether the failure is due to a transport error or a 5xx
+// status code), in which case a fallback endpoint may be tried.
+func (c *client) fetchURL(ctx context.Context, endpoint, secret, token, userIP string) (Response, bool, error) {
 	values := url.Values{
-		"secret":   {c.secret},
-		"response": {token},
+		c.fieldNames.secret:   {secret},
+		c.fieldNames.response: {token},
+	}
+	if userIP != "" && !c.omitRemoteIP {
+		values[c.fieldNames.remoteIP] = []string{userIP}
+	}
+
+	if c.curlLogger != nil {
+		c.curlLogger(c.curlCommand(endpoint, values))
+	}
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return Response{}, false, xerrors.Errorf("error creating POST request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request = request.WithContext(ctx)
+
+	res, err := c.httpClient.Do(request)
+	if err != nil {
+		return Response{}, true, xerrors.Errorf("error making POST request: %w", err)
+	}
+	defer func() {
+		// Drain any unread portion of the body (e.g. the body of an error
+		// response, or trailing data after the JSON) before closing it, so
+		// that the underlying connection can be reused. Oversized bodies are
+		// not drained beyond the maximum size, since reusing the connection is
+		// not worth reading them.
+		if c.limits.MaxBytes > 0 {
+			_, _ = io.CopyN(ioutil.Discard, res.Body, c.limits.MaxBytes)
+		} else {
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+		}
+		res.Body.Close()
+	}()
+
+	if res.StatusCode == http.StatusNotFound {
+		return Response{}, false, xerrors.Errorf("error making POST request: %w", &EndpointNotFoundError{
+			URL: endpoint,
+		})
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return Response{}, res.StatusCode >= 500, xerrors.Errorf("error making POST request: %w", &HTTPStatusError{
+			StatusCode: res.StatusCode,
+		})
+	}
+
+	if c.requireJSON {
+		contentType := res.Header.Get("Content-Type")
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			return Response{}, false, xerrors.Errorf("error reading response body: %w", &UnexpectedResponseError{
+				ContentType: contentType,
+			})
+		}
+	}
+
+	var reader io.Reader = res.Body
+	if c.limits.MaxBytes > 0 {
+		// Read one byte beyond the maximum, to detect oversized bodies
+		reader = io.LimitReader(res.Body, c.limits.MaxBytes+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return Response{}, false, xerrors.Errorf("error reading response body: %w", err)
+	}
+	if c.limits.MaxBytes > 0 && int64(len(body)) > c.limits.MaxBytes {
+		return Response{}, false, xerrors.Errorf("error reading response body: %w", &ResponseTooLargeError{
+			MaxBytes: c.limits.MaxBytes,
+		})
+	}
+
+	parse := parseResponse
+	if c.lenient {
+		parse = parseResponseLenient
+	}
+	response, err := parse(body)
+	if err != nil {
+		return response, false, xerrors.Errorf("error unmarshalling response body: %w", err)
+	}
+
+	// A missing or malformed Date header leaves serverDate as the zero time.
+	response.serverDate, _ = http.ParseTime(res.Header.Get("Date"))
+	return response, false, nil
+}
+
+// curlCommand renders a request to the verification endpoint at the provided
+// URL with the provided form values as a curl command, with the secret
+// redacted.
+func (c *client) curlCommand(endpoint string, values url.Values) string {
+	redactedValues := url.Values{}
+	for k, v := range values {
+		redactedValues[k] = v
+	}
+	redactedValues.Set(c.fieldNames.secret, redacted)
+	return fmt.Sprintf("curl -X POST -H %s --data %s %s",
+		shellQuote("Content-Type: application/x-www-form-urlencoded"),
+		shellQuote(redactedValues.Encode()),
+		shellQuote(endpoint),
+	)
+}
+
+// shellQuote quotes s for use as a single argument in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// parseResponse decodes a response body returned by the reCAPTCHA verification
+// endpoint, which must be a JSON object.
+func parseResponse(body []byte) (Response, error) {
+	if bytes.Equal(bytes.TrimSpace(body), []byte("null")) {
+		return Response{}, xerrors.New("response body is null")
+	}
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Response{}, err
+	}
+	return response, nil
+}
+
+// parseResponseLenient decodes a response body returned by the reCAPTCHA
+// verification endpoint field by field. If any fields are malformed, the
+// partially decoded response is returned along with a *DecodeError.
+func parseResponseLenient(body []byte) (Response, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return Response{}, err
+	}
+	if fields == nil {
+		return Response{}, xerrors.New("response body is null")
+	}
+
+	var response Response
+	targets := map[fieldSet]interface{}{
+		successField:     &response.Success,
+		scoreField:       &response.Score,
+		actionField:      &response.Action,
+		challengeTsField: &response.ChallengeTs,
+		hostnameField:    &response.Hostname,
+		errorCodesField:  &response.ErrorCodes,
+	}
+	errs := map[string]error{}
+	for name, raw := range fields {
+		// Match field names case-insensitively, as in UnmarshalJSON
+		target, ok := targets[responseFieldSet(name)]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, target); err != nil {
+			errs[name] = err
+		}
+	}
+
+	response.present, response.Extra = classifyFields(fields)
+	for field := range errs {
+		response.present &^= responseFieldSet(field)
+	}
+
+	if len(errs) > 0 {
+		return response, &DecodeError{
+			Errors: errs,
+		}
+	}
+	return response, nil
+}
+
+// Response represents a response from the reCAPTCHA token verification
+// endpoint. The validity of the token can be verified via the Verify method.
+type Response struct {
+	Success     bool      `json:"success"`
+	Score       float64   `json:"score"`
+	Action      string    `json:"action"`
+	ChallengeTs time.Time `json:"challenge_ts"`
+	Hostname    string    `json:"hostname"`
+	ErrorCodes  []string  `json:"error-codes"`
+
+	// Extra contains any fields of the response which do not correspond to
+	// one of the fields above, e.g. fields added by a proxy in front of the
+	// verification endpoint. It is nil if there are no such fields.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	// Which of the fields above were present in the response
+	present fieldSet
+	// The value of the verification endpoint's Date response header, if any
+	serverDate time.Time
+	// Set by Inspect on its copy of the response, to collect the name of the
+	// criterion being applied, as provided to Named
+	criterionName *string
+}
+
+// fieldSet is a set of the fields of Response which are decoded from the
+// verification endpoint's response, used to record which were present.
+type fieldSet uint8
+
+const (
+	successField fieldSet = 1 << iota
+	scoreField
+	actionField
+	challengeTsField
+	hostnameField
+	errorCodesField
+)
+
+// v2Fields and v3Fields are the fields present in successful responses for
+// reCAPTCHA v2 and v3 tokens, respectively (excluding "error-codes", which is
+// optional).
+const (
+	v2Fields = successField | challengeTsField | hostnameField
+	v3Fields = v2Fields | scoreField | actionField
+)
+
+// responseFields are the JSON names of the fields of Response which are
+// decoded from the verification endpoint's response, in order.
+var responseFields = []struct {
+	name  string
+	field fieldSet
+}{
+	{"success", successField},
+	{"score", scoreField},
+	{"action", actionField},
+	{"challenge_ts", challengeTsField},
+	{"hostname", hostnameField},
+	{"error-codes", errorCodesField},
+}
+
+// responseFieldSet returns the fieldSet containing the response field with the
+// provided JSON name, or an empty fieldSet if there is no such field. Like
+// encoding/json, field names are matched case-insensitively.
+func responseFieldSet(name string) fieldSet {
+	for _, f := range responseFields {
+		if strings.EqualFold(name, f.name) {
+			return f.field
+		}
+	}
+	return 0
+}
+
+// classifyFields returns the set of response fields present (and not null)
+// among the provided fields, along with the remaining fields, which do not
+// correspond to a response field. The remaining fields are nil if there are
+// none.
+func classifyFields(fields map[string]json.RawMessage) (fieldSet, map[string]json.RawMessage) {
+	var (
+		present fieldSet
+		extra   map[string]json.RawMessage
+	)
+	for name, raw := range fields {
+		if field := responseFieldSet(name); field != 0 {
+			if string(raw) != "null" {
+				present |= field
+			}
+			continue
+		}
+		if extra == nil {
+			extra = map[string]json.RawMessage{}
+		}
+		extra[name] = raw
+	}
+	return present, extra
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. In addition to
+// decoding the response's fields, it keeps track of which of them were present
+// (see HasScore and PresentFields), and captures any unknown fields in Extra.
+//
+// The "challenge_ts" field is decoded as an RFC 3339 timestamp, so the
+// variations returned by the verification endpoint over time are all accepted:
+// whole seconds ("2019-08-25T16:20:00Z"), fractional seconds
+// ("2019-08-25T16:20:00.123Z"), and an explicit UTC offset
+// ("2019-08-25T16:20:00+00:00"). Timestamps without a UTC offset are rejected.
+func (r *Response) UnmarshalJSON(data []byte) error {
+	// Decode via a type without the UnmarshalJSON method, to avoid recursion
+	type response Response
+	if err := json.Unmarshal(data, (*response)(r)); err != nil {
+		// Report errors against Response, as if it were decoded directly
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			typeErr.Struct = "Response"
+		}
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	r.present, r.Extra = classifyFields(fields)
+	return nil
+}
+
+// HasScore reports whether the "score" field was present in the response,
+// which distinguishes a score of 0 from a response without a score (e.g. one
+// from reCAPTCHA v2).
+func (r *Response) HasScore() bool {
+	return r.present&scoreField != 0
+}
+
+// PresentFields returns the JSON names of the standard fields (i.e. "success",
+// "score", "action", "challenge_ts", "hostname", and "error-codes") which were
+// present in the response, in that order. Fields whose value was null are not
+// considered present.
+func (r *Response) PresentFields() []string {
+	var names []string
+	for _, f := range responseFields {
+		if r.present&f.field != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// SchemaVersion is a heuristic which infers the version of reCAPTCHA that the
+// response belongs to from the fields present in it. It returns 3 if the
+// response has exactly the fields of a successful reCAPTCHA v3 response
+// ("success", "score", "action", "challenge_ts", and "hostname", plus the
+// optional "error-codes"), 2 if it has exactly those of a successful
+// reCAPTCHA v2 response (the same, minus "score" and "action"), and 0
+// otherwise, including if it has any extra fields. A change in the result
+// for the same kind of token indicates that the shape of the verification
+// endpoint's responses has drifted.
+func (r *Response) SchemaVersion() int {
+	if len(r.Extra) > 0 {
+		return 0
+	}
+	switch r.present &^ errorCodesField {
+	case v3Fields:
+		return 3
+	case v2Fields:
+		return 2
 	}
-	if userIP != "" {
-		values["remoteip"] = []string{userIP}
+	return 0
+}
+
+// ServerDate returns the time reported by the verification endpoint's Date
+// response header, or the zero time if the header was missing or malformed
+// (or the response was not returned by Fetch).
+func (r *Response) ServerDate() time.Time {
+	return r.serverDate
+}
+
+// HasErrors reports whether the response contains any error codes.
+func (r *Response) HasErrors() bool {
+	return len(r.ErrorCodes) > 0
+}
+
+// IsSuccess reports whether the response passes the default check performed by
+// Verify, i.e. whether "success" is true and "error-codes" is empty. A
+// malformed response may have a "success" field of true along with error
+// codes, in which case Success is true but IsSuccess is false.
+func (r *Response) IsSuccess() bool {
+	return r.Success && !r.HasErrors()
+}
+
+// redacted is the value that replaces redacted fields in the copy of a
+// Response returned by Redacted.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the response, suitable for logging, in which the
+// fields with the provided names are masked. The "action" and "hostname"
+// fields are identified by their JSON names, and each of the "error-codes" is
+// masked if that name is provided. Any other name refers to a field in Extra,
+// whose value is replaced with the JSON string "REDACTED". Names which do not
+// correspond to a present field are ignored. The original response is not
+// modified.
+func (r Response) Redacted(fields ...string) Response {
+	for _, field := range fields {
+		switch field {
+		case "action":
+			r.Action = redacted
+		case "hostname":
+			r.Hostname = redacted
+		case "error-codes":
+			errorCodes := make([]string, len(r.ErrorCodes))
+			for i := range errorCodes {
+				errorCodes[i] = redacted
+			}
+			r.ErrorCodes = errorCodes
+		default:
+			if _, ok := r.Extra[field]; !ok {
+				continue
+			}
+			extra := make(map[string]json.RawMessage, len(r.Extra))
+			for k, v := range r.Extra {
+				extra[k] = v
+			}
+			extra[field] = json.RawMessage(`"` + redacted + `"`)
+			r.Extra = extra
+		}
+	}
+	return r
+}
+
+// Verify checks whether the response represents a valid token. It returns an
+// error if the token is invalid (i.e. if Success is false or ErrorCodes is
+// non-empty). Typically, the error will be of type *VerificationError.
+// However, if additional optional verification criteria are provided, their
+// respective error types may be returned as well.
+//
+// The default check of the Success and ErrorCodes fields is always performed
+// first. The criteria are then applied in the order provided, and Verify
+// returns as soon as one of them fails, so cheap criteria (e.g. Hostname,
+// Action) should be provided before expensive ones.
+func (r *Response) Verify(criteria ...Criterion) error {
+	if !r.IsSuccess() {
+		return &VerificationError{
+			ErrorCodes: r.ErrorCodes,
+		}
+	}
+
+	return r.VerifyLenient(criteria...)
+}
+
+// VerifyLenient is like Verify, but skips the default check of the Success and
+// ErrorCodes fields, applying only the provided criteria. Unsuccessful
+// responses are therefore accepted unless a criterion rejects them, so it
+// should only be used in conjunction with criteria which inspect both fields
+// themselves (e.g. MaxErrorCodes).
+func (r *Response) VerifyLenient(criteria ...Criterion) error {
+	for _, criterion := range criteria {
+		if err := criterion(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyWithWarnings is like Verify, but accepts two groups of criteria. The
+// errCriteria are applied exactly as in Verify, and if any of them fail, the
+// error is returned. Otherwise, all of the warnCriteria are applied, and the
+// errors from any that fail are returned as warnings, without causing the
+// verification to fail. This makes it possible to, for example, require
+// step-up authentication for a slightly low score rather than rejecting it.
+func (r *Response) VerifyWithWarnings(errCriteria, warnCriteria []Criterion) (warnings []error, err error) {
+	if err := r.Verify(errCriteria...); err != nil {
+		return nil, err
+	}
+	for _, criterion := range warnCriteria {
+		if err := criterion(r); err != nil {
+			warnings = append(warnings, err)
+		}
+	}
+	return warnings, nil
+}
+
+// CriterionResult is the result of applying a single criterion, as returned by
+// Inspect. Each result is identified by the index of its criterion in the
+// arguments to Inspect, and by its name, if the criterion was wrapped via
+// Named.
+type CriterionResult struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+// Inspect applies every one of the provided criteria to the response, without
+// stopping at the first failure, and returns the result of each in order. Like
+// VerifyLenient, it skips the default check of the Success and ErrorCodes
+// fields. It is intended for diagnostics, e.g. showing which criteria a
+// response passed or failed; use Verify to decide whether a token is valid.
+func (r *Response) Inspect(criteria ...Criterion) []CriterionResult {
+	results := make([]CriterionResult, len(criteria))
+	for i, criterion := range criteria {
+		var name string
+		inspected := *r
+		inspected.criterionName = &name
+		err := criterion(&inspected)
+		results[i] = CriterionResult{
+			Index: i,
+			Name:  name,
+			Err:   err,
+		}
+	}
+	return results
+}
+
+// Named wraps the provided criterion, attaching a name to it (e.g. "min-score"),
+// which is reported in the CriterionResult returned by Inspect. If named
+// criteria are nested, the outermost name is reported. The criterion's result
+// is passed through unchanged.
+func Named(name string, c Criterion) Criterion {
+	return func(r *Response) error {
+		if r.criterionName != nil && *r.criterionName == "" {
+			*r.criterionName = name
+		}
+		return c(r)
+	}
+}
+
+// Timed wraps the provided criterion, reporting how long each application of
+// it takes to the sink, along with the provided name. The criterion's result is
+// passed through unchanged. This is mainly useful for tuning criteria which
+// make network calls, such as HostnameInCIDR.
+func Timed(name string, c Criterion, sink func(name string, d time.Duration)) Criterion {
+	return func(r *Response) error {
+		start := time.Now()
+		err := c(r)
+		sink(name, time.Since(start))
+		return err
+	}
+}
+
+// VerifyMany verifies each of the provided responses (e.g. stored responses
+// being analyzed offline) using the provided criteria, as in Verify, and
+// returns the resulting errors in the same order. The error for a response
+// which passes verification is nil. The results can be tallied with
+// Summarize.
+func VerifyMany(responses []Response, criteria ...Criterion) []error {
+	errs := make([]error, len(responses))
+	for i := range responses {
+		errs[i] = responses[i].Verify(criteria...)
+	}
+	return errs
+}
+
+// VerifySummary contains counts of passed and failed verifications, as
+// returned by Summarize.
+type VerifySummary struct {
+	Passed int
+	Failed int
+}
+
+// Summarize counts the nil (passed) and non-nil (failed) errors in the
+// provided slice, e.g. as returned by VerifyMany.
+func Summarize(errs []error) VerifySummary {
+	var summary VerifySummary
+	for _, err := range errs {
+		if err != nil {
+			summary.Failed++
+		} else {
+			summary.Passed++
+		}
+	}
+	return summary
+}
+
+// AggregateErrorCodes counts the occurrences of each error code across the
+// provided responses (e.g. stored failures being analyzed during an
+// incident), keyed by error code. The result is empty if none of the responses
+// have error codes.
+func AggregateErrorCodes(responses []Response) map[string]int {
+	counts := map[string]int{}
+	for i := range responses {
+		for _, code := range responses[i].ErrorCodes {
+			counts[code]++
+		}
+	}
+	return counts
+}
+
+// Quorum verifies each of the provided responses (e.g. for several tokens
+// required by a high-value action) using the provided criteria, as in
+// VerifyMany, and succeeds if at least n of them pass verification. Returns
+// *QuorumError, which reports how many passed along with each response's
+// error, if fewer than n pass.
+func Quorum(n int, responses []Response, criteria ...Criterion) error {
+	errs := VerifyMany(responses, criteria...)
+	if summary := Summarize(errs); summary.Passed < n {
+		return &QuorumError{
+			Required: n,
+			Passed:   summary.Passed,
+			Errs:     errs,
+		}
+	}
+	return nil
+}
+
+// ConsistentAcross checks whether the provided responses (e.g. for several
+// tokens collected during a multi-step form) all have the same hostname and
+// action. Returns *InconsistentResponseError for the first response which
+// differs from the first response in the slice.
+func ConsistentAcross(responses []Response) error {
+	for i := 1; i < len(responses); i++ {
+		if responses[i].Hostname != responses[0].Hostname {
+			return &InconsistentResponseError{
+				Index:    i,
+				Field:    "hostname",
+				Expected: responses[0].Hostname,
+				Actual:   responses[i].Hostname,
+			}
+		}
+		if responses[i].Action != responses[0].Action {
+			return &InconsistentResponseError{
+				Index:    i,
+				Field:    "action",
+				Expected: responses[0].Action,
+				Actual:   responses[i].Action,
+			}
+		}
+	}
+	return nil
+}
+
+// Criterion is an optional token verification criterion that can be applied
+// when a token is verified via the Verify method.
+type Criterion func(r *Response) error
+
+// Hostname is an optional verification criterion which ensures that the
+// hostname of the website where the reCAPTCHA was presented matches one of the
+// provided hostnames. Since the verification endpoint returns bare hostnames,
+// any scheme or port in the provided hostnames is ignored, e.g.
+// "https://niche.com" and "niche.com:443" are both treated as "niche.com".
+// Returns *InvalidHostnameError if the hostname is not correct.
+func Hostname(hostnames ...string) Criterion {
+	sanitized := make([]string, len(hostnames))
+	for i, hostname := range hostnames {
+		sanitized[i] = sanitizeHostname(hostname)
+	}
+	return func(r *Response) error {
+		for _, hostname := range sanitized {
+			if hostname == r.Hostname {
+				return nil
+			}
+		}
+		return &InvalidHostnameError{
+			Hostname: r.Hostname,
+		}
+	}
+}
+
+// HostnameIgnoreWWW is like Hostname, but treats a "www." prefix as optional
+// on both the provided hostnames and the response's hostname, so that e.g.
+// "niche.com" matches "www.niche.com" and vice versa. Returns
+// *InvalidHostnameError if the hostname is not correct.
+func HostnameIgnoreWWW(hostnames ...string) Criterion {
+	trimmed := make([]string, len(hostnames))
+	for i, hostname := range hostnames {
+		trimmed[i] = strings.TrimPrefix(sanitizeHostname(hostname), "www.")
+	}
+	return func(r *Response) error {
+		actual := strings.TrimPrefix(r.Hostname, "www.")
+		for _, hostname := range trimmed {
+			if hostname == actual {
+				return nil
+			}
+		}
+		return &InvalidHostnameError{
+			Hostname: r.Hostname,
+		}
 	}
+}
 
-	request, err := http.NewRequest(http.MethodPost, c.url, strings.NewReader(values.Encode()))
-	if err != nil {
-		return Response{}, xerrors.Errorf("error creating POST request: %w", err)
+// sanitizeHostname strips the scheme, port, and any path from a configured
+// hostname, e.g. "https://niche.com:443/" becomes "niche.com". Hostnames which
+// cannot be parsed are returned unchanged.
+func sanitizeHostname(hostname string) string {
+	if strings.Contains(hostname, "://") {
+		if u, err := url.Parse(hostname); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+		return hostname
 	}
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	request = request.WithContext(ctx)
-
-	res, err := c.httpClient.Do(request)
-	if err != nil {
-		return Response{}, xerrors.Errorf("error making POST request: %w", err)
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		return host
 	}
-	defer res.Body.Close()
+	return hostname
+}
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return Response{}, xerrors.Errorf("error reading response body: %w", err)
+// HostnameMatchesSNI is an optional verification criterion which ensures that
+// the hostname of the website where the reCAPTCHA was presented matches the
+// TLS server name (SNI) of the provided incoming request. Returns
+// *MissingHostnameError if the request was not made over TLS or did not
+// provide a server name, or *InvalidHostnameError if the hostname is not
+// correct.
+func HostnameMatchesSNI(req *http.Request) Criterion {
+	return func(r *Response) error {
+		if req.TLS == nil || req.TLS.ServerName == "" {
+			return &MissingHostnameError{
+				Source: "TLS server name",
+			}
+		}
+		return Hostname(req.TLS.ServerName)(r)
 	}
+}
 
-	var response Response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return Response{}, xerrors.Errorf("error unmarshalling response body: %w", err)
+// HostnameMatchesOrigin is an optional verification criterion which ensures
+// that the hostname of the website where the reCAPTCHA was presented matches
+// the host of the Origin header of the provided incoming request (ignoring its
+// scheme and port). This is mainly useful for tokens submitted via XHR or
+// fetch, for which browsers always send an Origin header. Returns
+// *MissingHostnameError if the request has no Origin header, or if it is
+// "null" or otherwise lacks a host, or *InvalidHostnameError if the hostname is
+// not correct.
+func HostnameMatchesOrigin(req *http.Request) Criterion {
+	return func(r *Response) error {
+		origin, err := url.Parse(req.Header.Get("Origin"))
+		if err != nil || origin.Hostname() == "" {
+			return &MissingHostnameError{
+				Source: "Origin header",
+			}
+		}
+		return Hostname(origin.Hostname())(r)
 	}
-
-	return response, nil
 }
 
-// Response represents a response from the reCAPTCHA token verification
-// endpoint. The validity of the token can be verified via the Verify method.
-type Response struct {
-	Success     bool      `json:"success"`
-	Score       float64   `json:"score"`
-	Action      string    `json:"action"`
-	ChallengeTs time.Time `json:"challenge_ts"`
-	Hostname    string    `json:"hostname"`
-	ErrorCodes  []string  `json:"error-codes"`
+// HostnameExpected is an optional verification criterion which ensures that
+// the hostname of the website where the reCAPTCHA was presented matches the
+// hostname returned by the provided function, which is called each time the
+// criterion is applied. This makes it possible to compute the expected
+// hostname per request (e.g. from the matched virtual host) without an
+// *http.Request in hand. Returns *MissingHostnameError if the function returns
+// an empty string, or *InvalidHostnameError if the hostname is not correct.
+func HostnameExpected(expected func() string) Criterion {
+	return func(r *Response) error {
+		hostname := expected()
+		if hostname == "" {
+			return &MissingHostnameError{
+				Source: "hostname from callback",
+			}
+		}
+		return Hostname(hostname)(r)
+	}
 }
 
-// Verify checks whether the response represents a valid token. It returns an
-// error if the token is invalid (i.e. if Success is false or ErrorCodes is
-// non-empty). Typically, the error will be of type *VerificationError.
-// However, if additional optional verification criteria are provided, their
-// respective error types may be returned as well.
-func (r *Response) Verify(criteria ...Criterion) error {
-	if !r.Success || len(r.ErrorCodes) > 0 {
-		return &VerificationError{
-			ErrorCodes: r.ErrorCodes,
+// HostnameNotIP is an optional verification criterion which ensures that the
+// hostname of the website where the reCAPTCHA was presented is a domain name,
+// rather than an IPv4 or IPv6 address (optionally in brackets, or with a
+// zone). A raw IP address is anomalous for a production site, and may indicate
+// tampering or a misconfigured widget. Returns *IPHostnameError if the
+// hostname is an IP address.
+func HostnameNotIP() Criterion {
+	return func(r *Response) error {
+		host := strings.TrimSuffix(strings.TrimPrefix(r.Hostname, "["), "]")
+		if i := strings.LastIndex(host, "%"); i >= 0 {
+			host = host[:i]
+		}
+		if net.ParseIP(host) != nil {
+			return &IPHostnameError{
+				Hostname: r.Hostname,
+			}
 		}
+		return nil
 	}
+}
 
-	for _, criterion := range criteria {
-		if err := criterion(r); err != nil {
-			return err
+// HostnameRegistrableDomain is an optional verification criterion which
+// ensures that the registrable domain (i.e. the effective top-level domain plus
+// one label, as determined by the Public Suffix List) of the website where the
+// reCAPTCHA was presented matches one of the provided registrable domains. For
+// example, HostnameRegistrableDomain("niche.co.uk") accepts "niche.co.uk" and
+// "www.niche.co.uk", but not "other.co.uk". Returns *InvalidHostnameError if
+// the hostname is not correct.
+func HostnameRegistrableDomain(domains ...string) Criterion {
+	return func(r *Response) error {
+		domain, err := publicsuffix.EffectiveTLDPlusOne(r.Hostname)
+		if err == nil {
+			for _, d := range domains {
+				if d == domain {
+					return nil
+				}
+			}
+		}
+		return &InvalidHostnameError{
+			Hostname: r.Hostname,
 		}
 	}
-
-	return nil
 }
 
-// Criterion is an optional token verification criterion that can be applied
-// when a token is verified via the Verify method.
-type Criterion func(r *Response) error
+// Resolver is a basic interface for a DNS resolver, as required by the
+// HostnameInCIDR criterion. The standard *net.Resolver satisfies this
+// interface.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
 
-// Hostname is an optional verification criterion which ensures that the
-// hostname of the website where the reCAPTCHA was presented matches one of the
-// provided hostnames. Returns *InvalidHostnameError if the hostname is not
-// correct.
-func Hostname(hostnames ...string) Criterion {
+// HostnameInCIDR is an optional verification criterion which ensures that the
+// hostname of the website where the reCAPTCHA was presented resolves to at
+// least one IP address within the provided CIDR ranges (e.g. "10.0.0.0/8"). If
+// resolver is nil, net.DefaultResolver is used. The lookup is made with a
+// background context, so it cannot be cancelled. Use HostnameInCIDRCtx with
+// VerifyContext to bound it. Returns *HostnameResolutionError if the hostname
+// cannot be resolved, or *InvalidHostnameError if none of the resolved
+// addresses fall within the ranges.
+func HostnameInCIDR(resolver Resolver, cidrs ...string) Criterion {
+	criterion := HostnameInCIDRCtx(resolver, cidrs...)
 	return func(r *Response) error {
-		for _, hostname := range hostnames {
-			if hostname == r.Hostname {
-				return nil
+		return criterion(context.Background(), r)
+	}
+}
+
+// HostnameInCIDRCtx is like HostnameInCIDR, but makes the lookup with the
+// context passed to VerifyContext, so that it is cancelled along with the
+// verification.
+func HostnameInCIDRCtx(resolver Resolver, cidrs ...string) CriterionCtx {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return func(ctx context.Context, r *Response) error {
+				return xerrors.Errorf("error parsing CIDR range: %w", err)
+			}
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return func(ctx context.Context, r *Response) error {
+		addrs, err := resolver.LookupIPAddr(ctx, r.Hostname)
+		if err != nil {
+			return &HostnameResolutionError{
+				Hostname: r.Hostname,
+				Err:      err,
+			}
+		}
+		for _, addr := range addrs {
+			for _, ipNet := range nets {
+				if ipNet.Contains(addr.IP) {
+					return nil
+				}
 			}
 		}
 		return &InvalidHostnameError{
@@ -189,14 +1466,266 @@ func Action(actions ...string) Criterion {
 	}
 }
 
+// ActionMatchesMethod is an optional verification criterion which ensures that
+// the website action associated with the reCAPTCHA is the one expected for the
+// method of the provided incoming request, according to the provided mapping
+// from methods (e.g. http.MethodGet) to actions. This catches tokens obtained
+// for one kind of request being submitted with another, e.g. a "get_profile"
+// token used to POST an update. Requests whose method is not in the mapping
+// are always rejected. Returns *InvalidActionError if the action is not
+// correct.
+func ActionMatchesMethod(req *http.Request, mapping map[string]string) Criterion {
+	return func(r *Response) error {
+		action, ok := mapping[req.Method]
+		if !ok {
+			return &InvalidActionError{
+				Action: r.Action,
+			}
+		}
+		return Action(action)(r)
+	}
+}
+
+// ActionRegexp is an optional verification criterion which ensures that the
+// website action associated with the reCAPTCHA matches the provided regular
+// expression. Returns *InvalidActionError if the action does not match.
+func ActionRegexp(re *regexp.Regexp) Criterion {
+	return func(r *Response) error {
+		if !re.MatchString(r.Action) {
+			return &InvalidActionError{
+				Action: r.Action,
+			}
+		}
+		return nil
+	}
+}
+
+// ActionNotIn is an optional verification criterion which ensures that the
+// website action associated with the reCAPTCHA does not match any of the
+// provided actions, e.g. those already used by earlier steps of a multi-step
+// flow (as recorded in the user's session). This prevents a token from an
+// earlier step from being replayed for a later one. Returns
+// *InvalidActionError if the action was previously used.
+func ActionNotIn(previous ...string) Criterion {
+	return func(r *Response) error {
+		for _, action := range previous {
+			if action == r.Action {
+				return &InvalidActionError{
+					Action: r.Action,
+				}
+			}
+		}
+		return nil
+	}
+}
+
 // Score is an optional verification criterion which ensures that the score
 // associated with the reCAPTCHA meets the minimum threshold. Returns
 // *InvalidScoreError if the score is below the threshold.
 func Score(threshold float64) Criterion {
+	return ScoreThresholdFunc(func() float64 {
+		return threshold
+	})
+}
+
+// ScoreIfPresent is like Score, but only enforces the minimum threshold if
+// the response actually included a score. This makes it possible to apply a
+// score threshold to a mix of reCAPTCHA v2 responses (which do not include a
+// score) and v3 responses. Returns *InvalidScoreError if the score is present
+// and below the threshold.
+func ScoreIfPresent(threshold float64) Criterion {
+	score := Score(threshold)
 	return func(r *Response) error {
-		if r.Score < threshold {
+		if !r.HasScore() {
+			return nil
+		}
+		return score(r)
+	}
+}
+
+// ScoreThresholdFunc is an optional verification criterion which ensures that
+// the score associated with the reCAPTCHA meets the minimum threshold returned
+// by the provided function, which is called each time a response is verified.
+// This makes it possible to adjust the threshold dynamically (e.g. during an
+// attack). Returns *InvalidScoreError if the score is below the threshold.
+func ScoreThresholdFunc(threshold func() float64) Criterion {
+	return func(r *Response) error {
+		if t := threshold(); r.Score < t {
 			return &InvalidScoreError{
+				Score:     r.Score,
+				Threshold: t,
+			}
+		}
+		return nil
+	}
+}
+
+// ScoreByHostname is an optional verification criterion which ensures that the
+// score associated with the reCAPTCHA meets the minimum threshold for the
+// hostname of the website where it was presented, e.g. to apply different
+// thresholds to different brands served by the same backend. Hostnames are
+// matched case-insensitively, and the fallback threshold applies to hostnames
+// without a threshold of their own. Returns *InvalidScoreError, whose
+// Threshold is the threshold applied, if the score is below it.
+func ScoreByHostname(thresholds map[string]float64, fallback float64) Criterion {
+	normalized := make(map[string]float64, len(thresholds))
+	for hostname, threshold := range thresholds {
+		normalized[strings.ToLower(hostname)] = threshold
+	}
+	return func(r *Response) error {
+		threshold, ok := normalized[strings.ToLower(r.Hostname)]
+		if !ok {
+			threshold = fallback
+		}
+		return Score(threshold)(r)
+	}
+}
+
+// ScoreVsBaseline is an optional verification criterion which ensures that the
+// score associated with the reCAPTCHA has not dropped more than maxDrop below
+// the provided baseline, e.g. the historical average score of a returning user.
+// A sudden drop for a normally human user may indicate an account takeover
+// attempt, even if the score would pass a global threshold. Returns
+// *ScoreDropError if the score is more than maxDrop below the baseline.
+func ScoreVsBaseline(baseline, maxDrop float64) Criterion {
+	return func(r *Response) error {
+		if baseline-r.Score > maxDrop {
+			return &ScoreDropError{
+				Score:    r.Score,
+				Baseline: baseline,
+				MaxDrop:  maxDrop,
+			}
+		}
+		return nil
+	}
+}
+
+// PercentileEstimator estimates percentiles of a distribution of scores, e.g.
+// a running histogram of the scores of recent traffic, as required by the
+// PercentileScore criterion. Implementations must be safe for concurrent use.
+type PercentileEstimator interface {
+	// Percentile returns the estimated score at the pth percentile, where p
+	// ranges from 0 to 100. It is up to the implementation what to return
+	// when there is too little data for a meaningful estimate (e.g. a fixed
+	// fallback threshold).
+	Percentile(p float64) float64
+}
+
+// PercentileScore is an optional verification criterion which ensures that the
+// score associated with the reCAPTCHA meets the pth percentile (from 0 to 100)
+// of the distribution tracked by the provided estimator, which is consulted
+// each time a response is verified. This makes it possible to adapt the
+// threshold to recent traffic, e.g. by rejecting scores below the 20th
+// percentile. Returns *InvalidScoreError, whose Threshold is the estimated
+// percentile, if the score is below it.
+func PercentileScore(estimator PercentileEstimator, p float64) Criterion {
+	return ScoreThresholdFunc(func() float64 {
+		return estimator.Percentile(p)
+	})
+}
+
+// WeightedScore is an optional verification criterion which scales the score
+// associated with the reCAPTCHA by the weight of its action, and ensures that
+// the resulting effective score meets the minimum threshold. This makes it
+// possible to apply a single threshold across actions of differing risk, e.g.
+// by weighting a risky "checkout" action below 1. Actions without a weight
+// have a weight of 1. Returns *InvalidWeightedScoreError if the effective score
+// is below the threshold.
+func WeightedScore(weights map[string]float64, threshold float64) Criterion {
+	return func(r *Response) error {
+		weight, ok := weights[r.Action]
+		if !ok {
+			weight = 1
+		}
+		if effective := r.Score * weight; effective < threshold {
+			return &InvalidWeightedScoreError{
+				Score:          r.Score,
+				Weight:         weight,
+				EffectiveScore: effective,
+				Threshold:      threshold,
+			}
+		}
+		return nil
+	}
+}
+
+// MaxErrorCodes is an optional verification criterion which ensures that the
+// response was successful and contains no more than n error codes. Since
+// Verify rejects any response with error codes, it is only useful in
+// conjunction with VerifyLenient, in which case it takes the place of the
+// default check of the Success and ErrorCodes fields. Returns
+// *VerificationError if the response was not successful or there are too many
+// error codes.
+func MaxErrorCodes(n int) Criterion {
+	return func(r *Response) error {
+		if !r.Success || len(r.ErrorCodes) > n {
+			return &VerificationError{
+				ErrorCodes: r.ErrorCodes,
+			}
+		}
+		return nil
+	}
+}
+
+// NoSecretErrors is an optional verification criterion which ensures that the
+// response's error codes do not include "missing-input-secret" or
+// "invalid-input-secret", which indicate a deployment problem rather than an
+// invalid token. Since Verify rejects any response with error codes before
+// applying criteria, it should be used with VerifyLenient, followed by
+// MaxErrorCodes(0), which performs the check of the Success and ErrorCodes
+// fields that VerifyLenient skips, e.g.:
+//
+//	err := response.VerifyLenient(recaptcha.NoSecretErrors(), recaptcha.MaxErrorCodes(0))
+//
+// NoSecretErrors does not check the Success field itself, so it must not be
+// passed to VerifyLenient alone.
+//
+// Returns *SecretConfigError if a secret-related error code is present.
+func NoSecretErrors() Criterion {
+	return func(r *Response) error {
+		for _, code := range r.ErrorCodes {
+			if code == "missing-input-secret" || code == "invalid-input-secret" {
+				return &SecretConfigError{
+					ErrorCodes: r.ErrorCodes,
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// RejectZeroScore is an optional verification criterion which rejects
+// responses with a score of exactly 0.0. Such a score indicates a near-certain
+// bot, even though "success" is true, so this criterion provides a safety net
+// for callers who do not otherwise enforce a minimum score via Score. It is not
+// applied by default, since reCAPTCHA v2 responses do not include a score (and
+// therefore decode with a score of 0.0). Returns *ZeroScoreError if the score
+// is zero.
+func RejectZeroScore() Criterion {
+	return func(r *Response) error {
+		if r.Score == 0 {
+			return &ZeroScoreError{}
+		}
+		return nil
+	}
+}
+
+// scoreEpsilon is the tolerance used when checking score quantization.
+const scoreEpsilon = 1e-9
+
+// ScoreQuantized is an optional verification criterion which ensures that the
+// score associated with the reCAPTCHA is a multiple of the provided (positive)
+// step, e.g. 0.1. Scores returned by the reCAPTCHA verification endpoint are
+// coarse-grained, so an overly precise score may indicate a tampered response.
+// If step is not a positive, finite number, every score is rejected. Returns
+// *InvalidScorePrecisionError if the score is not a multiple of step.
+func ScoreQuantized(step float64) Criterion {
+	return func(r *Response) error {
+		steps := r.Score / step
+		if !(step > 0) || math.IsInf(step, 1) || math.Abs(steps-math.Round(steps)) > scoreEpsilon {
+			return &InvalidScorePrecisionError{
 				Score: r.Score,
+				Step:  step,
 			}
 		}
 		return nil
@@ -214,8 +1743,15 @@ var now = time.Now
 // *InvalidChallengeTsError if the challenge timestamp is outside the valid
 // window.
 func ChallengeTs(window time.Duration) Criterion {
+	return ChallengeTsWithClock(window, now)
+}
+
+// ChallengeTsWithClock is like ChallengeTs, but uses the provided clock
+// function, rather than time.Now, to determine the current time. This makes it
+// possible to deterministically test code which relies on ChallengeTs.
+func ChallengeTsWithClock(window time.Duration, clock func() time.Time) Criterion {
 	return func(r *Response) error {
-		if diff := now().Sub(r.ChallengeTs); diff > window {
+		if diff := clock().Sub(r.ChallengeTs); diff > window {
 			return &InvalidChallengeTsError{
 				ChallengeTs: r.ChallengeTs,
 				Diff:        diff,
@@ -224,3 +1760,274 @@ func ChallengeTs(window time.Duration) Criterion {
 		return nil
 	}
 }
+
+// ChallengeTsSince is like ChallengeTs, but measures the age of the challenge
+// timestamp relative to the provided time at which the incoming request was
+// received (e.g. as captured by middleware), rather than the current time.
+// This avoids false rejections due to time spent processing the request before
+// verification. See also ChallengeTsSinceReceived. Returns
+// *InvalidChallengeTsError if the challenge timestamp is outside the valid
+// window.
+func ChallengeTsSince(received time.Time, window time.Duration) Criterion {
+	return ChallengeTsWithClock(window, func() time.Time {
+		return received
+	})
+}
+
+// ChallengeTsVsServerDate is like ChallengeTs, but measures the age of the
+// challenge timestamp relative to the time reported by the verification
+// endpoint's Date response header (see ServerDate), rather than the local
+// clock. This avoids false rejections due to local clock skew. If the header
+// was missing or malformed, the local clock is used instead. Returns
+// *InvalidChallengeTsError if the challenge timestamp is outside the valid
+// window.
+func ChallengeTsVsServerDate(window time.Duration) Criterion {
+	return func(r *Response) error {
+		clock := now
+		if date := r.ServerDate(); !date.IsZero() {
+			clock = func() time.Time {
+				return date
+			}
+		}
+		return ChallengeTsWithClock(window, clock)(r)
+	}
+}
+
+// ClockSkewWithin is an optional verification criterion which ensures that the
+// local clock is within the provided tolerance of the time reported by the
+// "server_time" extra field, which some proxies add to the verification
+// endpoint's response. This helps decide whether time-based criteria such as
+// ChallengeTs can be trusted. The field may be an RFC 3339 timestamp or a
+// number of seconds since the Unix epoch. If the field is absent, the
+// criterion passes. Returns *ClockSkewError if the skew exceeds the tolerance,
+// or an error if the field is malformed.
+func ClockSkewWithin(tolerance time.Duration) Criterion {
+	return func(r *Response) error {
+		raw, ok := r.Extra["server_time"]
+		if !ok {
+			return nil
+		}
+		serverTime, err := parseServerTime(raw)
+		if err != nil {
+			return xerrors.Errorf("invalid server_time: %w", err)
+		}
+		skew := now().Sub(serverTime)
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew > tolerance {
+			return &ClockSkewError{
+				ServerTime: serverTime,
+				Skew:       skew,
+				Tolerance:  tolerance,
+			}
+		}
+		return nil
+	}
+}
+
+// parseServerTime decodes a "server_time" extra field, which may be either an
+// RFC 3339 timestamp or a number of seconds since the Unix epoch.
+func parseServerTime(raw json.RawMessage) (time.Time, error) {
+	var t time.Time
+	if err := json.Unmarshal(raw, &t); err == nil {
+		return t, nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		return time.Time{}, xerrors.Errorf("expected RFC 3339 timestamp or Unix time, got %s", raw)
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(), nil
+}
+
+// ChallengeTsUTC is an optional verification criterion which ensures that the
+// response's challenge timestamp has a UTC offset of zero. The reCAPTCHA
+// verification endpoint always returns UTC timestamps, so a non-UTC timestamp
+// may indicate a tampered or proxied response. Returns
+// *NonUTCChallengeTsError if the timestamp is not UTC.
+func ChallengeTsUTC() Criterion {
+	return func(r *Response) error {
+		if _, offset := r.ChallengeTs.Zone(); offset != 0 {
+			return &NonUTCChallengeTsError{
+				ChallengeTs: r.ChallengeTs,
+			}
+		}
+		return nil
+	}
+}
+
+// processStart is the time at which the current process started (or, more
+// precisely, at which this package was initialized). It is a variable so that
+// it can be overridden in tests.
+var processStart = time.Now()
+
+// ChallengeTsAfterStart is an optional verification criterion which ensures
+// that the response's challenge timestamp does not predate the start of the
+// current process. This is a crude replay guard for short-lived processes
+// (e.g. serverless instances): a token issued before the process started
+// cannot have been requested from it. It offers little protection for
+// long-running servers, whose start time soon falls outside the 2 minute
+// window enforced by the verification endpoint anyway; use ChallengeTs
+// instead. Returns *ChallengeTsBeforeStartError if the timestamp predates the
+// process start.
+func ChallengeTsAfterStart() Criterion {
+	return func(r *Response) error {
+		if r.ChallengeTs.Before(processStart) {
+			return &ChallengeTsBeforeStartError{
+				ChallengeTs: r.ChallengeTs,
+				Start:       processStart,
+			}
+		}
+		return nil
+	}
+}
+
+// ChallengeTsInWindow is an optional verification criterion which ensures that
+// the response's challenge timestamp falls within a daily window, e.g.
+// business hours, in the provided location. The window is given by offsets
+// from midnight, and includes start but excludes end, e.g. 9*time.Hour and
+// 17*time.Hour for 9am to 5pm. If start is after end, the window spans
+// midnight. A nil location is treated as UTC. Since out-of-hours tokens are
+// not necessarily invalid, this criterion is best used to flag responses for
+// further review (e.g. via Inspect or EvaluateWith) rather than to reject them
+// outright. Returns *ChallengeTsOutsideWindowError if the timestamp falls
+// outside the window.
+func ChallengeTsInWindow(loc *time.Location, start, end time.Duration) Criterion {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return func(r *Response) error {
+		ts := r.ChallengeTs.In(loc)
+		hour, min, sec := ts.Clock()
+		timeOfDay := time.Duration(hour)*time.Hour +
+			time.Duration(min)*time.Minute +
+			time.Duration(sec)*time.Second +
+			time.Duration(ts.Nanosecond())
+
+		var inWindow bool
+		if start <= end {
+			inWindow = timeOfDay >= start && timeOfDay < end
+		} else {
+			inWindow = timeOfDay >= start || timeOfDay < end
+		}
+		if !inWindow {
+			return &ChallengeTsOutsideWindowError{
+				ChallengeTs: ts,
+				Start:       start,
+				End:         end,
+			}
+		}
+		return nil
+	}
+}
+
+// ExtraEquals is an optional verification criterion which ensures that the
+// extra (i.e. non-standard) field of the response with the provided key has
+// the provided value. String fields are compared by their decoded value; other
+// fields are compared by their raw JSON encoding, e.g. `42` or `true`. Returns
+// *MissingFieldError if the field is not present, or *MismatchError if it has
+// a different value.
+func ExtraEquals(key, value string) Criterion {
+	return func(r *Response) error {
+		raw, ok := r.Extra[key]
+		if !ok {
+			return &MissingFieldError{
+				Field: key,
+			}
+		}
+		actual := string(raw)
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			actual = s
+		}
+		if actual != value {
+			return &MismatchError{
+				Field:    key,
+				Expected: value,
+				Actual:   actual,
+			}
+		}
+		return nil
+	}
+}
+
+// ExpectSchema is an optional verification criterion which ensures that the
+// response's SchemaVersion is the provided version. It is intended as an early
+// warning of changes to the verification endpoint's responses, e.g. in canary
+// deployments. Returns *SchemaMismatchError if the version differs.
+func ExpectSchema(version int) Criterion {
+	return func(r *Response) error {
+		if actual := r.SchemaVersion(); actual != version {
+			return &SchemaMismatchError{
+				Expected:      version,
+				Actual:        actual,
+				PresentFields: r.PresentFields(),
+			}
+		}
+		return nil
+	}
+}
+
+// WithExtra is an optional verification criterion which validates the
+// response's extra (i.e. non-standard) fields using the provided function,
+// making it possible to check arbitrary fields added by a proxy in front of
+// the verification endpoint. The function receives Extra (which is nil if
+// there are no extra fields), and its error is returned verbatim.
+func WithExtra(fn func(extra map[string]json.RawMessage) error) Criterion {
+	return func(r *Response) error {
+		return fn(r.Extra)
+	}
+}
+
+// Matches is an optional verification criterion which ensures that the
+// response's fields match those of the expected response, except for the
+// fields named in ignore (identified by their JSON names, e.g.
+// "challenge_ts"). It is mainly intended for contract tests. Returns
+// *MismatchError for the first field that does not match.
+func Matches(expected Response, ignore ...string) Criterion {
+	ignored := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		ignored[field] = true
+	}
+
+	return func(r *Response) error {
+		fields := []struct {
+			name     string
+			equal    bool
+			expected interface{}
+			actual   interface{}
+		}{
+			{"success", r.Success == expected.Success, expected.Success, r.Success},
+			{"score", r.Score == expected.Score, expected.Score, r.Score},
+			{"action", r.Action == expected.Action, expected.Action, r.Action},
+			{"challenge_ts", r.ChallengeTs.Equal(expected.ChallengeTs), expected.ChallengeTs, r.ChallengeTs},
+			{"hostname", r.Hostname == expected.Hostname, expected.Hostname, r.Hostname},
+			{"error-codes", equalStrings(r.ErrorCodes, expected.ErrorCodes), expected.ErrorCodes, r.ErrorCodes},
+		}
+		for _, field := range fields {
+			if !field.equal && !ignored[field.name] {
+				return &MismatchError{
+					Field:    field.name,
+					Expected: field.expected,
+					Actual:   field.actual,
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// equalStrings reports whether a and b contain the same strings, treating nil
+// and empty slices as equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
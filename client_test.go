@@ -2,13 +2,22 @@ package recaptcha
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -23,6 +32,27 @@ func (m *httpClientMock) Do(req *http.Request) (*http.Response, error) {
 	return m.doStub(req)
 }
 
+type resolverMock struct {
+	lookupIPAddrStub func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+func (m *resolverMock) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return m.lookupIPAddrStub(ctx, host)
+}
+
+type cacheMock struct {
+	getStub func(key string) ([]byte, bool)
+	setStub func(key string, val []byte, ttl time.Duration)
+}
+
+func (m *cacheMock) Get(key string) ([]byte, bool) {
+	return m.getStub(key)
+}
+
+func (m *cacheMock) Set(key string, val []byte, ttl time.Duration) {
+	m.setStub(key, val, ttl)
+}
+
 type readCloserMock struct {
 	readStub  func(p []byte) (n int, err error)
 	closeStub func() error
@@ -36,7 +66,23 @@ func (m *readCloserMock) Close() error {
 	return m.closeStub()
 }
 
+// drainMock is a response body which records how much of it remained unread
+// when it was closed.
+type drainMock struct {
+	*strings.Reader
+	closed    bool
+	remaining int
+}
+
+func (m *drainMock) Close() error {
+	m.closed = true
+	m.remaining = m.Len()
+	return nil
+}
+
 func TestNewClient(t *testing.T) {
+	cache := NewMemoryCache()
+
 	testCases := []struct {
 		name     string
 		secret   string
@@ -47,9 +93,11 @@ func TestNewClient(t *testing.T) {
 			name:   "NoOptions",
 			secret: "secret",
 			expected: &client{
-				secret:     "secret",
+				secrets:    []string{"secret"},
 				url:        DefaultURL,
 				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				stats:      &stats{},
 			},
 		},
 		{
@@ -63,13 +111,15 @@ func TestNewClient(t *testing.T) {
 				}),
 			},
 			expected: &client{
-				secret: "secret",
-				url:    DefaultURL,
+				secrets: []string{"secret"},
+				url:     DefaultURL,
 				httpClient: &http.Client{
 					Transport: &http.Transport{
 						MaxIdleConnsPerHost: 1,
 					},
 				},
+				fieldNames: defaultFieldNames,
+				stats:      &stats{},
 			},
 		},
 		{
@@ -79,9 +129,155 @@ func TestNewClient(t *testing.T) {
 				SetURL("url"),
 			},
 			expected: &client{
-				secret:     "secret",
+				secrets:    []string{"secret"},
 				url:        "url",
 				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				stats:      &stats{},
+			},
+		},
+		{
+			name:   "SetOmitRemoteIP",
+			secret: "secret",
+			options: []Option{
+				SetOmitRemoteIP(),
+			},
+			expected: &client{
+				secrets:      []string{"secret"},
+				url:          DefaultURL,
+				httpClient:   http.DefaultClient,
+				fieldNames:   defaultFieldNames,
+				omitRemoteIP: true,
+				stats:        &stats{},
+			},
+		},
+		{
+			name:   "SetLimits",
+			secret: "secret",
+			options: []Option{
+				SetLimits(Limits{
+					Timeout:  time.Second,
+					MaxBytes: 1024,
+				}),
+			},
+			expected: &client{
+				secrets:    []string{"secret"},
+				url:        DefaultURL,
+				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				limits: Limits{
+					Timeout:  time.Second,
+					MaxBytes: 1024,
+				},
+				stats: &stats{},
+			},
+		},
+		{
+			name:   "SetLimits/Defaults",
+			secret: "secret",
+			options: []Option{
+				SetLimits(Limits{
+					Timeout:  -time.Second,
+					MaxBytes: 0,
+				}),
+			},
+			expected: &client{
+				secrets:    []string{"secret"},
+				url:        DefaultURL,
+				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				limits:     DefaultLimits,
+				stats:      &stats{},
+			},
+		},
+		{
+			name:   "SetSecrets",
+			secret: "secret",
+			options: []Option{
+				SetSecrets("new", "old"),
+			},
+			expected: &client{
+				secrets:    []string{"new", "old"},
+				url:        DefaultURL,
+				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				stats:      &stats{},
+			},
+		},
+		{
+			name:   "SetSecrets/Empty",
+			secret: "secret",
+			options: []Option{
+				SetSecrets(),
+			},
+			expected: &client{
+				secrets:    []string{"secret"},
+				url:        DefaultURL,
+				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				stats:      &stats{},
+			},
+		},
+		{
+			name:   "SetSecrets/Max",
+			secret: "secret",
+			options: []Option{
+				SetSecrets("a", "b", "c", "d"),
+			},
+			expected: &client{
+				secrets:    []string{"a", "b", "c"},
+				url:        DefaultURL,
+				httpClient: http.DefaultClient,
+				fieldNames: defaultFieldNames,
+				stats:      &stats{},
+			},
+		},
+		{
+			name:   "SetFieldNames",
+			secret: "secret",
+			options: []Option{
+				SetFieldNames("key", "token", "ip"),
+			},
+			expected: &client{
+				secrets:    []string{"secret"},
+				url:        DefaultURL,
+				httpClient: http.DefaultClient,
+				fieldNames: fieldNames{
+					secret:   "key",
+					response: "token",
+					remoteIP: "ip",
+				},
+				stats: &stats{},
+			},
+		},
+		{
+			name:   "SetResponseCache",
+			secret: "secret",
+			options: []Option{
+				SetResponseCache(cache),
+			},
+			expected: &client{
+				secrets:       []string{"secret"},
+				url:           DefaultURL,
+				httpClient:    http.DefaultClient,
+				fieldNames:    defaultFieldNames,
+				responseCache: cache,
+				stats:         &stats{},
+			},
+		},
+		{
+			name:   "SetActionBinding",
+			secret: "secret",
+			options: []Option{
+				SetActionBinding(cache),
+			},
+			expected: &client{
+				secrets:       []string{"secret"},
+				url:           DefaultURL,
+				httpClient:    http.DefaultClient,
+				fieldNames:    defaultFieldNames,
+				actionBinding: cache,
+				stats:         &stats{},
 			},
 		},
 	}
@@ -96,6 +292,42 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestWith(t *testing.T) {
+	httpClient := &http.Client{}
+	original := NewClient("secret",
+		SetHTTPClient(httpClient),
+	)
+	clone := original.With(
+		SetSecrets("tenant"),
+		SetURL("url"),
+	)
+
+	expectedOriginal := &client{
+		secrets:    []string{"secret"},
+		url:        DefaultURL,
+		httpClient: httpClient,
+		fieldNames: defaultFieldNames,
+		stats:      &stats{},
+	}
+	if !reflect.DeepEqual(expectedOriginal, original) {
+		t.Errorf("Expected original:\n%#v\nActual:\n%#v\n", expectedOriginal, original)
+	}
+
+	expectedClone := &client{
+		secrets:    []string{"tenant"},
+		url:        "url",
+		httpClient: httpClient,
+		fieldNames: defaultFieldNames,
+		stats:      &stats{},
+	}
+	if !reflect.DeepEqual(expectedClone, clone) {
+		t.Errorf("Expected clone:\n%#v\nActual:\n%#v\n", expectedClone, clone)
+	}
+	if clone.(*client).httpClient != original.(*client).httpClient {
+		t.Errorf("Expected clone to share HTTP client with original")
+	}
+}
+
 func TestFetch(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -137,6 +369,7 @@ func TestFetch(t *testing.T) {
 				SetHTTPClient(&httpClientMock{
 					doStub: func(req *http.Request) (*http.Response, error) {
 						return &http.Response{
+							StatusCode: http.StatusOK,
 							Body: &readCloserMock{
 								readStub: func(p []byte) (n int, err error) {
 									return 0, errors.New("AAHHH")
@@ -153,6 +386,43 @@ func TestFetch(t *testing.T) {
 			userIP: "192.169.0.1",
 			err:    errors.New("AAHHH"),
 		},
+		{
+			name: "StatusCode/NotFound",
+			client: NewClient("secret",
+				SetURL("https://www.google.com/recaptcha/api/siteverfy"),
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusNotFound,
+							Body:       ioutil.NopCloser(strings.NewReader("<html>Not Found</html>")),
+						}, nil
+					},
+				}),
+			),
+			token:  "token",
+			userIP: "192.169.0.1",
+			err: &EndpointNotFoundError{
+				URL: "https://www.google.com/recaptcha/api/siteverfy",
+			},
+		},
+		{
+			name: "StatusCode/Error",
+			client: NewClient("secret",
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusInternalServerError,
+							Body:       ioutil.NopCloser(strings.NewReader("<html>Server Error</html>")),
+						}, nil
+					},
+				}),
+			),
+			token:  "token",
+			userIP: "192.169.0.1",
+			err: &HTTPStatusError{
+				StatusCode: http.StatusInternalServerError,
+			},
+		},
 		{
 			name: "Unmarshal/Error",
 			client: NewClient("secret",
@@ -160,7 +430,8 @@ func TestFetch(t *testing.T) {
 					doStub: func(req *http.Request) (*http.Response, error) {
 						body := `{"score":"invalid"}`
 						return &http.Response{
-							Body: ioutil.NopCloser(strings.NewReader(body)),
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
 						}, nil
 					},
 				}),
@@ -189,7 +460,8 @@ func TestFetch(t *testing.T) {
 							"error-codes": []
 						}`
 						return &http.Response{
-							Body: ioutil.NopCloser(strings.NewReader(body)),
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
 						}, nil
 					},
 				}),
@@ -203,6 +475,7 @@ func TestFetch(t *testing.T) {
 				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
 				Hostname:    "niche.com",
 				ErrorCodes:  []string{},
+				present:     v3Fields | errorCodesField,
 			},
 		},
 	}
@@ -220,242 +493,4468 @@ func TestFetch(t *testing.T) {
 	}
 }
 
-func TestVerify(t *testing.T) {
-	// Mock time.Now() function for sake of ChallengeTs tests
-	current := time.Now()
-	now = func() time.Time {
-		return current
+func TestFetchEmptyToken(t *testing.T) {
+	client := NewClient("secret",
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				t.Error("Unexpected HTTP request")
+				return nil, errors.New("unexpected HTTP request")
+			},
+		}),
+	)
+
+	actual, err := client.Fetch(context.Background(), "", "192.168.0.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := Response{
+		Success:    false,
+		ErrorCodes: []string{"missing-input-response"},
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+	}
+
+	expectedErr := &VerificationError{
+		ErrorCodes: []string{"missing-input-response"},
+	}
+	if err := actual.Verify(); !reflect.DeepEqual(expectedErr, err) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expectedErr, err)
+	}
+}
+
+func TestFetchSecrets(t *testing.T) {
+	// Returns a successful response only for the "new" secret
+	secretMock := func(calls *[]string) HTTPClient {
+		return &httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				if err := req.ParseForm(); err != nil {
+					return nil, err
+				}
+				secret := req.PostForm.Get("secret")
+				*calls = append(*calls, secret)
+				body := `{"success": false, "error-codes": ["invalid-input-secret"]}`
+				if secret == "new" {
+					body = `{"success": true, "error-codes": []}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}
 	}
 
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
 	testCases := []struct {
 		name     string
-		response Response
-		criteria []Criterion
-		expected error
+		ctx      context.Context
+		secrets  []string
+		expected Response
+		calls    []string
+		err      error
 	}{
 		{
-			name: "VerificationError/SuccessFalse",
-			response: Response{
-				Success:     false,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  nil,
+			name:    "First",
+			ctx:     context.Background(),
+			secrets: []string{"new", "old"},
+			expected: Response{
+				Success:    true,
+				ErrorCodes: []string{},
+				present:    successField | errorCodesField,
 			},
-			expected: &VerificationError{},
+			calls: []string{"new"},
 		},
 		{
-			name: "VerificationError/ErrorCodes",
-			response: Response{
-				Success:     true,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{"invalid-input-secret"},
+			name:    "Fallback",
+			ctx:     context.Background(),
+			secrets: []string{"old", "new"},
+			expected: Response{
+				Success:    true,
+				ErrorCodes: []string{},
+				present:    successField | errorCodesField,
 			},
-			expected: &VerificationError{
+			calls: []string{"old", "new"},
+		},
+		{
+			name:    "AllFail",
+			ctx:     context.Background(),
+			secrets: []string{"old", "older"},
+			expected: Response{
+				Success:    false,
 				ErrorCodes: []string{"invalid-input-secret"},
+				present:    successField | errorCodesField,
 			},
+			calls: []string{"old", "older"},
 		},
 		{
-			name: "InvalidHostnameError",
-			response: Response{
-				Success:     true,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "nathanjcochran.com",
-				ErrorCodes:  []string{},
-			},
-			criteria: []Criterion{
-				Hostname("niche.com"),
+			name:    "ContextCanceled",
+			ctx:     canceled,
+			secrets: []string{"old", "new"},
+			calls:   []string{"old"},
+			err:     context.Canceled,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []string
+			client := NewClient("secret",
+				SetSecrets(testCase.secrets...),
+				SetHTTPClient(secretMock(&calls)),
+			)
+			actual, err := client.Fetch(testCase.ctx, "token", "")
+			err = xerrors.Unwrap(err)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			} else if !reflect.DeepEqual(testCase.calls, calls) {
+				t.Errorf("Expected calls:\n%#v\nActual:\n%#v\n", testCase.calls, calls)
+			}
+		})
+	}
+}
+
+func TestFetchFallbackURL(t *testing.T) {
+	const (
+		primary  = "https://primary.example.com/siteverify"
+		fallback = "https://fallback.example.com/siteverify"
+	)
+
+	// Responds to requests for the primary URL using the provided stub, and
+	// successfully to requests for the fallback URL
+	fallbackMock := func(calls *[]string, primaryStub func() (*http.Response, error)) HTTPClient {
+		return &httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				*calls = append(*calls, req.URL.String())
+				if req.URL.String() == primary {
+					return primaryStub()
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+				}, nil
 			},
-			expected: &InvalidHostnameError{
-				Hostname: "nathanjcochran.com",
+		}
+	}
+	status := func(statusCode int, body string) func() (*http.Response, error) {
+		return func() (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		}
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		fallbackURL string
+		primaryStub func() (*http.Response, error)
+		expected    Response
+		calls       []string
+		err         error
+	}{
+		{
+			name:        "PrimarySuccess",
+			ctx:         context.Background(),
+			fallbackURL: fallback,
+			primaryStub: status(http.StatusOK, `{"success": false}`),
+			expected: Response{
+				Success: false,
+				present: successField,
 			},
+			calls: []string{primary},
 		},
 		{
-			name: "InvalidActionError",
-			response: Response{
-				Success:     true,
-				Score:       .5,
-				Action:      "register",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
-			},
-			criteria: []Criterion{
-				Action("login"),
+			name:        "TransportError",
+			ctx:         context.Background(),
+			fallbackURL: fallback,
+			primaryStub: func() (*http.Response, error) {
+				return nil, errors.New("connection refused")
 			},
-			expected: &InvalidActionError{
-				Action: "register",
+			expected: Response{
+				Success: true,
+				present: successField,
 			},
+			calls: []string{primary, fallback},
 		},
 		{
-			name: "InvalidScoreError",
-			response: Response{
-				Success:     true,
-				Score:       .4,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
+			name:        "ServerError",
+			ctx:         context.Background(),
+			fallbackURL: fallback,
+			primaryStub: status(http.StatusBadGateway, ""),
+			expected: Response{
+				Success: true,
+				present: successField,
 			},
-			criteria: []Criterion{
-				Score(.5),
+			calls: []string{primary, fallback},
+		},
+		{
+			name:        "ClientError",
+			ctx:         context.Background(),
+			fallbackURL: fallback,
+			primaryStub: status(http.StatusTooManyRequests, ""),
+			calls:       []string{primary},
+			err: &HTTPStatusError{
+				StatusCode: http.StatusTooManyRequests,
 			},
-			expected: &InvalidScoreError{
-				Score: .4,
+		},
+		{
+			name:        "NotFound",
+			ctx:         context.Background(),
+			fallbackURL: fallback,
+			primaryStub: status(http.StatusNotFound, ""),
+			calls:       []string{primary},
+			err: &EndpointNotFoundError{
+				URL: primary,
 			},
 		},
 		{
-			name: "InvalidChallengeTsError",
-			response: Response{
-				Success:     true,
-				Score:       .4,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
+			name:        "NoFallback",
+			ctx:         context.Background(),
+			fallbackURL: "",
+			primaryStub: status(http.StatusServiceUnavailable, ""),
+			calls:       []string{primary},
+			err: &HTTPStatusError{
+				StatusCode: http.StatusServiceUnavailable,
 			},
-			criteria: []Criterion{
-				ChallengeTs(500 * time.Millisecond),
+		},
+		{
+			name:        "ContextCanceled",
+			ctx:         canceled,
+			fallbackURL: fallback,
+			primaryStub: status(http.StatusServiceUnavailable, ""),
+			calls:       []string{primary},
+			err: &HTTPStatusError{
+				StatusCode: http.StatusServiceUnavailable,
 			},
-			expected: &InvalidChallengeTsError{
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []string
+			client := NewClient("secret",
+				SetURL(primary),
+				SetFallbackURL(testCase.fallbackURL),
+				SetHTTPClient(fallbackMock(&calls, testCase.primaryStub)),
+			)
+			actual, err := client.Fetch(testCase.ctx, "token", "")
+			err = xerrors.Unwrap(err)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			} else if !reflect.DeepEqual(testCase.calls, calls) {
+				t.Errorf("Expected calls:\n%#v\nActual:\n%#v\n", testCase.calls, calls)
+			}
+		})
+	}
+}
+
+func TestFetchDrainsBody(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "Success",
+			statusCode: http.StatusOK,
+			body:       `{"success": true}`,
+		},
+		{
+			name:       "NotFound",
+			statusCode: http.StatusNotFound,
+			body:       `<html>Not Found</html>`,
+		},
+		{
+			name:       "ServerError",
+			statusCode: http.StatusInternalServerError,
+			body:       `<html>Internal Server Error</html>`,
+		},
+		{
+			name:       "UnmarshalError",
+			statusCode: http.StatusOK,
+			body:       `{"success": tru`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			body := &drainMock{
+				Reader: strings.NewReader(testCase.body),
+			}
+			client := NewClient("secret",
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: testCase.statusCode,
+							Body:       body,
+						}, nil
+					},
+				}),
+			)
+			client.Fetch(context.Background(), "token", "")
+			if !body.closed {
+				t.Errorf("Expected body to be closed")
+			}
+			if body.remaining != 0 {
+				t.Errorf("Expected body to be drained, %d bytes remaining", body.remaining)
+			}
+		})
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	// Responds with the provided body, or blocks until the request's context
+	// is done if the body is empty
+	limitsMock := func(body string) HTTPClient {
+		return &httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				if body == "" {
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		limits   Limits
+		body     string
+		expected Response
+		err      error
+	}{
+		{
+			name: "WithinLimits",
+			limits: Limits{
+				MaxBytes: int64(len(`{"success": true}`)),
+			},
+			body: `{"success": true}`,
+			expected: Response{
+				Success: true,
+				present: successField,
+			},
+		},
+		{
+			name: "TooLarge",
+			limits: Limits{
+				MaxBytes: int64(len(`{"success": true}`)) - 1,
+			},
+			body: `{"success": true}`,
+			err: &ResponseTooLargeError{
+				MaxBytes: int64(len(`{"success": true}`)) - 1,
+			},
+		},
+		{
+			name: "Timeout",
+			limits: Limits{
+				Timeout: time.Millisecond,
+			},
+			body: "",
+			err:  context.DeadlineExceeded,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient("secret",
+				SetLimits(testCase.limits),
+				SetHTTPClient(limitsMock(testCase.body)),
+			)
+			actual, err := client.Fetch(context.Background(), "token", "")
+			err = xerrors.Unwrap(err)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// Rejects the "bad" secret, and the token for any other secret
+	validateMock := func(calls *[]string) HTTPClient {
+		return &httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				if err := req.ParseForm(); err != nil {
+					return nil, err
+				}
+				secret := req.PostForm.Get("secret")
+				*calls = append(*calls, secret)
+				if secret == "error" {
+					return nil, errors.New("AAHHH")
+				}
+				body := `{"success": false, "error-codes": ["invalid-input-response"]}`
+				if secret == "bad" {
+					body = `{"success": false, "error-codes": ["invalid-input-secret"]}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		secrets []string
+		calls   []string
+		err     error
+	}{
+		{
+			name:    "Valid",
+			secrets: []string{"good"},
+			calls:   []string{"good"},
+			err:     nil,
+		},
+		{
+			name:    "InvalidSecret",
+			secrets: []string{"bad"},
+			calls:   []string{"bad"},
+			err: &SecretConfigError{
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+		},
+		{
+			name:    "SecondSecretInvalid",
+			secrets: []string{"good", "bad"},
+			calls:   []string{"good", "bad"},
+			err: &SecretConfigError{
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+		},
+		{
+			name:    "RequestError",
+			secrets: []string{"error", "good"},
+			calls:   []string{"error"},
+			err:     xerrors.Errorf("error making POST request: %w", errors.New("AAHHH")),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []string
+			client := NewClient("secret",
+				SetSecrets(testCase.secrets...),
+				SetHTTPClient(validateMock(&calls)),
+			)
+			err := xerrors.Unwrap(client.Validate(context.Background()))
+			if testCase.err == nil || err == nil {
+				if testCase.err != err {
+					t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+				}
+			} else if testCase.err.Error() != err.Error() {
+				t.Errorf("Expected error:\n%s\nActual:\n%s\n", testCase.err, err)
+			}
+			if !reflect.DeepEqual(testCase.calls, calls) {
+				t.Errorf("Expected calls:\n%#v\nActual:\n%#v\n", testCase.calls, calls)
+			}
+			if stats := client.Stats(); stats.Fetches != 0 {
+				t.Errorf("Expected no fetches in stats, got %d", stats.Fetches)
+			}
+		})
+	}
+}
+
+func TestFetchRequireJSONContentType(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		expected    Response
+		err         error
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			expected: Response{
+				Success: true,
+				present: successField,
+			},
+		},
+		{
+			name:        "JSON/Charset",
+			contentType: "application/json; charset=utf-8",
+			expected: Response{
+				Success: true,
+				present: successField,
+			},
+		},
+		{
+			name:        "JSON/Case",
+			contentType: "Application/JSON",
+			expected: Response{
+				Success: true,
+				present: successField,
+			},
+		},
+		{
+			name:        "HTML",
+			contentType: "text/html; charset=utf-8",
+			err: &UnexpectedResponseError{
+				ContentType: "text/html; charset=utf-8",
+			},
+		},
+		{
+			name:        "Missing",
+			contentType: "",
+			err:         &UnexpectedResponseError{},
+		},
+		{
+			name:        "Malformed",
+			contentType: "application/json; charset",
+			err: &UnexpectedResponseError{
+				ContentType: "application/json; charset",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient("secret",
+				SetRequireJSONContentType(),
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						header := http.Header{}
+						if testCase.contentType != "" {
+							header.Set("Content-Type", testCase.contentType)
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     header,
+							Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+						}, nil
+					},
+				}),
+			)
+			actual, err := client.Fetch(context.Background(), "token", "")
+			err = xerrors.Unwrap(err)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestFetchObserver(t *testing.T) {
+	type contextKey struct{}
+
+	testCases := []struct {
+		name     string
+		doStub   func(req *http.Request) (*http.Response, error)
+		expected Response
+		err      error
+	}{
+		{
+			name: "Error",
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("AAHHH")
+			},
+			err: errors.New("AAHHH"),
+		},
+		{
+			name: "Success",
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true, "score": 0.5}`)),
+				}, nil
+			},
+			expected: Response{
+				Success: true,
+				Score:   .5,
+				present: successField | scoreField,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				calls    int
+				value    interface{}
+				response Response
+				err      error
+			)
+			client := NewClient("secret",
+				SetHTTPClient(&httpClientMock{
+					doStub: testCase.doStub,
+				}),
+				SetObserver(func(ctx context.Context, r Response, e error) {
+					calls++
+					value = ctx.Value(contextKey{})
+					response = r
+					err = xerrors.Unwrap(e)
+				}),
+			)
+			ctx := context.WithValue(context.Background(), contextKey{}, "user")
+			client.Fetch(ctx, "token", "192.169.0.1")
+
+			if calls != 1 {
+				t.Errorf("Expected 1 call, got %d", calls)
+			} else if value != "user" {
+				t.Errorf("Expected context value %q, got %#v", "user", value)
+			} else if !reflect.DeepEqual(testCase.expected, response) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, response)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestFetchRemoteIP(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  []Option
+		userIP   string
+		expected url.Values
+	}{
+		{
+			name:   "UserIP",
+			userIP: "192.169.0.1",
+			expected: url.Values{
+				"secret":   {"secret"},
+				"response": {"token"},
+				"remoteip": {"192.169.0.1"},
+			},
+		},
+		{
+			name:   "NoUserIP",
+			userIP: "",
+			expected: url.Values{
+				"secret":   {"secret"},
+				"response": {"token"},
+			},
+		},
+		{
+			name: "SetOmitRemoteIP",
+			options: []Option{
+				SetOmitRemoteIP(),
+			},
+			userIP: "192.169.0.1",
+			expected: url.Values{
+				"secret":   {"secret"},
+				"response": {"token"},
+			},
+		},
+		{
+			name: "SetFieldNames",
+			options: []Option{
+				SetFieldNames("key", "token", "ip"),
+			},
+			userIP: "192.169.0.1",
+			expected: url.Values{
+				"key":   {"secret"},
+				"token": {"token"},
+				"ip":    {"192.169.0.1"},
+			},
+		},
+		{
+			name: "SetFieldNames/Partial",
+			options: []Option{
+				SetFieldNames("", "token", ""),
+			},
+			userIP: "192.169.0.1",
+			expected: url.Values{
+				"secret":   {"secret"},
+				"token":    {"token"},
+				"remoteip": {"192.169.0.1"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual url.Values
+			options := append(testCase.options, SetHTTPClient(&httpClientMock{
+				doStub: func(req *http.Request) (*http.Response, error) {
+					if err := req.ParseForm(); err != nil {
+						return nil, err
+					}
+					actual = req.PostForm
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+					}, nil
+				},
+			}))
+			client := NewClient("secret", options...)
+			if _, err := client.Fetch(context.Background(), "token", testCase.userIP); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFetchRequiredFields(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fields   []string
+		body     string
+		expected Response
+		err      error
+	}{
+		{
+			name:   "MissingScore",
+			fields: []string{"action", "score"},
+			body:   `{"success": true, "action": "login"}`,
+			err: &MissingFieldError{
+				Field: "score",
+			},
+		},
+		{
+			name:   "ZeroScore",
+			fields: []string{"score"},
+			body:   `{"success": true, "score": 0.0}`,
+			expected: Response{
+				Success: true,
+				present: successField | scoreField,
+			},
+		},
+		{
+			name:   "MissingAction",
+			fields: []string{"action", "score"},
+			body:   `{"success": true, "score": 0.5}`,
+			err: &MissingFieldError{
+				Field: "action",
+			},
+		},
+		{
+			name:   "NotSuccessful",
+			fields: []string{"action", "score"},
+			body:   `{"success": false, "error-codes": ["invalid-input-response"]}`,
+			expected: Response{
+				Success:    false,
+				ErrorCodes: []string{"invalid-input-response"},
+				present:    successField | errorCodesField,
+			},
+		},
+		{
+			name:   "UnknownField",
+			fields: []string{"foo"},
+			body:   `{"success": true, "score": 0.5, "action": "login"}`,
+			err:    errors.New(`unknown required field: "foo"`),
+		},
+		{
+			name:   "Success",
+			fields: []string{"action", "score"},
+			body:   `{"success": true, "score": 0.5, "action": "login"}`,
+			expected: Response{
+				Success: true,
+				Score:   .5,
+				Action:  "login",
+				present: successField | scoreField | actionField,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient("secret",
+				SetRequiredFields(testCase.fields...),
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(testCase.body)),
+						}, nil
+					},
+				}),
+			)
+			actual, err := client.Fetch(context.Background(), "token", "")
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			} else if fmt.Sprint(testCase.err) != fmt.Sprint(err) {
+				t.Errorf("Expected error:\n%v\nActual:\n%v\n", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestFetchActionBinding(t *testing.T) {
+	type call struct {
+		token   string
+		actions []string
+		err     error
+	}
+
+	testCases := []struct {
+		name          string
+		calls         []call
+		expectedCalls int
+	}{
+		{
+			name: "Consistent",
+			calls: []call{
+				{token: "a", actions: []string{"login"}},
+				{token: "a", actions: []string{"login"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "Conflicting",
+			calls: []call{
+				{token: "a", actions: []string{"login"}},
+				{
+					token:   "a",
+					actions: []string{"checkout"},
+					err: &ActionBindingError{
+						BoundAction: "login",
+						Action:      "checkout",
+					},
+				},
+				{token: "a", actions: []string{"login"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "MultipleActions",
+			calls: []call{
+				{token: "a", actions: []string{"login", "register"}},
+				{
+					token:   "a",
+					actions: []string{"login"},
+					err: &ActionBindingError{
+						BoundAction: "login,register",
+						Action:      "login",
+					},
+				},
+			},
+			expectedCalls: 1,
+		},
+		{
+			name: "Reordered",
+			calls: []call{
+				{token: "a", actions: []string{"login", "signup"}},
+				{token: "a", actions: []string{"signup", "login", "signup"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "DifferentTokens",
+			calls: []call{
+				{token: "a", actions: []string{"login"}},
+				{token: "b", actions: []string{"checkout"}},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "NoExpectedActions",
+			calls: []call{
+				{token: "a"},
+				{token: "a", actions: []string{"checkout"}},
+				{token: "a"},
+			},
+			expectedCalls: 3,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				entries = map[string][]byte{}
+				ttls    []time.Duration
+				calls   int
+			)
+			cache := &cacheMock{
+				getStub: func(key string) ([]byte, bool) {
+					val, ok := entries[key]
+					return val, ok
+				},
+				setStub: func(key string, val []byte, ttl time.Duration) {
+					entries[key] = val
+					ttls = append(ttls, ttl)
+				},
+			}
+			client := NewClient("secret",
+				SetActionBinding(cache),
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						calls++
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(`{"success": true, "action": "login"}`)),
+						}, nil
+					},
+				}),
+			)
+
+			for i, call := range testCase.calls {
+				ctx := context.Background()
+				if call.actions != nil {
+					ctx = WithExpectedActions(ctx, call.actions...)
+				}
+				_, err := client.Fetch(ctx, call.token, "")
+				if !reflect.DeepEqual(call.err, err) {
+					t.Errorf("Call %d: Expected:\n%#v\nActual:\n%#v\n", i, call.err, err)
+				}
+			}
+			if calls != testCase.expectedCalls {
+				t.Errorf("Expected %d requests, got %d", testCase.expectedCalls, calls)
+			}
+			for _, ttl := range ttls {
+				if ttl != actionBindingTTL {
+					t.Errorf("Expected TTL %s, got %s", actionBindingTTL, ttl)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchActionBindingConcurrent(t *testing.T) {
+	client := NewClient("secret",
+		SetActionBinding(NewMemoryCache()),
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+				}, nil
+			},
+		}),
+	)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted = map[string]bool{}
+	)
+	for i := 0; i < 20; i++ {
+		action := "login"
+		if i%2 == 1 {
+			action = "checkout"
+		}
+		wg.Add(1)
+		go func(action string) {
+			defer wg.Done()
+			ctx := WithExpectedActions(context.Background(), action)
+			if _, err := client.Fetch(ctx, "token", ""); err == nil {
+				mu.Lock()
+				accepted[action] = true
+				mu.Unlock()
+			} else if _, ok := err.(*ActionBindingError); !ok {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}(action)
+	}
+	wg.Wait()
+
+	if len(accepted) != 1 {
+		t.Errorf("Expected token to be accepted for exactly 1 action, got %v", accepted)
+	}
+}
+
+func TestFetchServerDate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   http.Header
+		expected time.Time
+	}{
+		{
+			name: "Valid",
+			header: http.Header{
+				"Date": {"Sun, 25 Aug 2019 16:20:00 GMT"},
+			},
+			expected: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+		},
+		{
+			name:     "Missing",
+			header:   http.Header{},
+			expected: time.Time{},
+		},
+		{
+			name: "Invalid",
+			header: http.Header{
+				"Date": {"yesterday"},
+			},
+			expected: time.Time{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient("secret",
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     testCase.header,
+							Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+						}, nil
+					},
+				}),
+			)
+			response, err := client.Fetch(context.Background(), "token", "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if actual := response.ServerDate(); !testCase.expected.Equal(actual) {
+				t.Errorf("Expected:\n%s\nActual:\n%s\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerifyCached(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	body := `{"success": true, "score": 0.4, "action": "login", "tenant_id": "niche"}`
+	expected := Response{
+		Success: true,
+		Score:   .4,
+		Action:  "login",
+		Extra: map[string]json.RawMessage{
+			"tenant_id": json.RawMessage(`"niche"`),
+		},
+		present:    successField | scoreField | actionField,
+		serverDate: time.Date(2019, 8, 25, 16, 19, 0, 0, time.UTC),
+	}
+
+	testCases := []struct {
+		name            string
+		cache           bool
+		elapsed         time.Duration
+		expectedFetches int
+	}{
+		{
+			name:            "NoCache",
+			cache:           false,
+			expectedFetches: 2,
+		},
+		{
+			name:            "Hit",
+			cache:           true,
+			elapsed:         time.Second,
+			expectedFetches: 1,
+		},
+		{
+			name:            "Miss/Stale",
+			cache:           true,
+			elapsed:         time.Minute,
+			expectedFetches: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fetches := 0
+			options := []Option{
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						fetches++
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header: http.Header{
+								"Date": {"Sun, 25 Aug 2019 16:19:00 GMT"},
+							},
+							Body: ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				}),
+			}
+			if testCase.cache {
+				options = append(options, SetResponseCache(NewMemoryCache()))
+			}
+			client := NewClient("secret", options...)
+
+			expectedErr := &InvalidScoreError{
+				Score:     .4,
+				Threshold: .5,
+			}
+			for i, criterion := range []Criterion{Action("login"), Score(.5)} {
+				if i > 0 {
+					current = current.Add(testCase.elapsed)
+				}
+				actual, err := client.VerifyCached(context.Background(), "token", "", 30*time.Second, criterion)
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+				}
+				if i == 0 && err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				} else if i > 0 && !reflect.DeepEqual(expectedErr, err) {
+					t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expectedErr, err)
+				}
+			}
+			if fetches != testCase.expectedFetches {
+				t.Errorf("Expected %d fetches, got %d", testCase.expectedFetches, fetches)
+			}
+		})
+	}
+}
+
+func TestVerifyCachedWith(t *testing.T) {
+	testCases := []struct {
+		name            string
+		opts            []Option
+		expectedErr     error
+		expectedFetches int
+	}{
+		{
+			name:            "SameConfig",
+			expectedFetches: 1,
+		},
+		{
+			name: "OtherSecret",
+			opts: []Option{SetSecrets("B")},
+			expectedErr: &VerificationError{
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+			expectedFetches: 2,
+		},
+		{
+			name: "OtherURL",
+			opts: []Option{SetURL("https://example.com/siteverify")},
+			expectedErr: &VerificationError{
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+			expectedFetches: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fetches := 0
+			base := NewClient("A",
+				SetResponseCache(NewMemoryCache()),
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						fetches++
+						body := `{"success": false, "error-codes": ["invalid-input-secret"]}`
+						if req.FormValue("secret") == "A" && req.URL.String() == DefaultURL {
+							body = `{"success": true}`
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(body)),
+						}, nil
+					},
+				}),
+			)
+			if _, err := base.VerifyCached(context.Background(), "token", "", time.Minute); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			_, err := base.With(testCase.opts...).VerifyCached(context.Background(), "token", "", time.Minute)
+			if !reflect.DeepEqual(testCase.expectedErr, err) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expectedErr, err)
+			}
+			if fetches != testCase.expectedFetches {
+				t.Errorf("Expected %d fetches, got %d", testCase.expectedFetches, fetches)
+			}
+		})
+	}
+}
+
+func TestVerifyCachedActionBinding(t *testing.T) {
+	fetches := 0
+	client := NewClient("secret",
+		SetResponseCache(NewMemoryCache()),
+		SetActionBinding(NewMemoryCache()),
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				fetches++
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true, "action": "login"}`)),
+				}, nil
+			},
+		}),
+	)
+
+	login := WithExpectedActions(context.Background(), "login")
+	if _, err := client.VerifyCached(login, "token", "", time.Minute); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := client.VerifyCached(login, "token", "", time.Minute); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	expected := &ActionBindingError{
+		BoundAction: "login",
+		Action:      "checkout",
+	}
+	checkout := WithExpectedActions(context.Background(), "checkout")
+	if _, err := client.VerifyCached(checkout, "token", "", time.Minute); !reflect.DeepEqual(expected, err) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, err)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected 1 fetch, got %d", fetches)
+	}
+}
+
+func TestFetchCurlLogger(t *testing.T) {
+	var curls []string
+	client := NewClient("my_secret",
+		SetURL("https://example.com/it's"),
+		SetCurlLogger(func(curl string) {
+			curls = append(curls, curl)
+		}),
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+				}, nil
+			},
+		}),
+	)
+	if _, err := client.Fetch(context.Background(), "token", "192.168.0.1"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []string{
+		`curl -X POST -H 'Content-Type: application/x-www-form-urlencoded' ` +
+			`--data 'remoteip=192.168.0.1&response=token&secret=REDACTED' ` +
+			`'https://example.com/it'\''s'`,
+	}
+	if !reflect.DeepEqual(expected, curls) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, curls)
+	}
+	if len(curls) > 0 && strings.Contains(curls[0], "my_secret") {
+		t.Errorf("Secret not redacted: %s", curls[0])
+	}
+}
+
+func TestFetchLenient(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected Response
+		fields   []string
+		err      bool
+	}{
+		{
+			name: "Invalid",
+			body: `{`,
+			err:  true,
+		},
+		{
+			name: "Null",
+			body: `null`,
+			err:  true,
+		},
+		{
+			name: "OneBadField",
+			body: `{
+				"success": true,
+				"score": "high",
+				"action": "login",
+				"challenge_ts": "2019-08-25T16:20:00Z",
+				"hostname": "niche.com",
+				"error-codes": []
+			}`,
+			expected: Response{
+				Success:     true,
+				Action:      "login",
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+				present:     v2Fields | actionField | errorCodesField,
+			},
+			fields: []string{"score"},
+		},
+		{
+			name: "SeveralBadFields",
+			body: `{
+				"success": true,
+				"score": 0.5,
+				"action": 1,
+				"challenge_ts": "yesterday",
+				"hostname": "niche.com"
+			}`,
+			expected: Response{
+				Success:  true,
+				Score:    .5,
+				Hostname: "niche.com",
+				present:  successField | scoreField | hostnameField,
+			},
+			fields: []string{"action", "challenge_ts"},
+		},
+		{
+			name: "Success",
+			body: `{"success": true, "score": 0.5, "action": "login"}`,
+			expected: Response{
+				Success: true,
+				Score:   .5,
+				Action:  "login",
+				present: successField | scoreField | actionField,
+			},
+		},
+		{
+			name: "MixedCase",
+			body: `{"success": true, "Score": 0.9, "ACTION": "login"}`,
+			expected: Response{
+				Success: true,
+				Score:   .9,
+				Action:  "login",
+				present: successField | scoreField | actionField,
+			},
+		},
+		{
+			name: "MixedCase/BadField",
+			body: `{"success": true, "Score": "high"}`,
+			expected: Response{
+				Success: true,
+				present: successField,
+			},
+			fields: []string{"Score"},
+		},
+		{
+			name: "Extra",
+			body: `{"success": true, "tenant_id": "niche", "score": "high"}`,
+			expected: Response{
+				Success: true,
+				Extra: map[string]json.RawMessage{
+					"tenant_id": json.RawMessage(`"niche"`),
+				},
+				present: successField,
+			},
+			fields: []string{"score"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient("secret",
+				SetLenientDecoding(),
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(testCase.body)),
+						}, nil
+					},
+				}),
+			)
+			actual, err := client.Fetch(context.Background(), "token", "")
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+
+			var decodeErr *DecodeError
+			switch {
+			case testCase.fields != nil:
+				if !xerrors.As(err, &decodeErr) {
+					t.Fatalf("Expected *DecodeError, got %#v", err)
+				}
+				var fields []string
+				for field := range decodeErr.Errors {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+				if !reflect.DeepEqual(testCase.fields, fields) {
+					t.Errorf("Expected fields:\n%#v\nActual:\n%#v\n", testCase.fields, fields)
+				}
+			case testCase.err:
+				if err == nil || xerrors.As(err, &decodeErr) {
+					t.Errorf("Expected non-decode error, got %#v", err)
+				}
+			case err != nil:
+				t.Errorf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestHostnameInCIDRCtx(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resolver := &resolverMock{
+		lookupIPAddrStub: func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return []net.IPAddr{
+				{IP: net.ParseIP("192.168.0.1")},
+			}, nil
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		cidrs    []string
+		expected error
+	}{
+		{
+			name:     "InRange",
+			ctx:      context.Background(),
+			cidrs:    []string{"192.168.0.0/16"},
+			expected: nil,
+		},
+		{
+			name:  "OutOfRange",
+			ctx:   context.Background(),
+			cidrs: []string{"10.0.0.0/8"},
+			expected: &InvalidHostnameError{
+				Hostname: "niche.com",
+			},
+		},
+		{
+			name:  "Canceled",
+			ctx:   canceled,
+			cidrs: []string{"192.168.0.0/16"},
+			expected: &HostnameResolutionError{
+				Hostname: "niche.com",
+				Err:      context.Canceled,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:  true,
+				Hostname: "niche.com",
+			}
+			actual := HostnameInCIDRCtx(resolver, testCase.cidrs...)(testCase.ctx, &response)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// Mock time.Now() function for sake of ChallengeTs tests
+	current := time.Now()
+	now = func() time.Time {
+		return current
+	}
+
+	// Stub resolver for sake of HostnameInCIDR tests
+	resolver := &resolverMock{
+		lookupIPAddrStub: func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			if host == "niche.com" {
+				return []net.IPAddr{
+					{IP: net.ParseIP("192.168.0.1")},
+				}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		response Response
+		criteria []Criterion
+		expected error
+	}{
+		{
+			name: "VerificationError/SuccessFalse",
+			response: Response{
+				Success:     false,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  nil,
+			},
+			expected: &VerificationError{},
+		},
+		{
+			name: "VerificationError/ErrorCodes",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{"invalid-input-secret"},
+			},
+			expected: &VerificationError{
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+		},
+		{
+			name: "InvalidHostnameError",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "nathanjcochran.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Hostname("niche.com"),
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "nathanjcochran.com",
+			},
+		},
+		{
+			name: "InvalidHostnameError/SNI",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "nathanjcochran.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameMatchesSNI(&http.Request{
+					TLS: &tls.ConnectionState{
+						ServerName: "niche.com",
+					},
+				}),
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "nathanjcochran.com",
+			},
+		},
+		{
+			name: "MissingHostnameError/SNI/NoTLS",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameMatchesSNI(&http.Request{}),
+			},
+			expected: &MissingHostnameError{
+				Source: "TLS server name",
+			},
+		},
+		{
+			name: "MissingHostnameError/SNI/NoServerName",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameMatchesSNI(&http.Request{
+					TLS: &tls.ConnectionState{},
+				}),
+			},
+			expected: &MissingHostnameError{
+				Source: "TLS server name",
+			},
+		},
+		{
+			name: "InvalidHostnameError/CIDR",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameInCIDR(resolver, "10.0.0.0/8"),
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "niche.com",
+			},
+		},
+		{
+			name: "HostnameResolutionError/CIDR",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "nathanjcochran.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameInCIDR(resolver, "10.0.0.0/8"),
+			},
+			expected: &HostnameResolutionError{
+				Hostname: "nathanjcochran.com",
+				Err:      errors.New("no such host"),
+			},
+		},
+		{
+			name: "InvalidHostnameError/RegistrableDomain",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "www.other.co.uk",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameRegistrableDomain("niche.co.uk"),
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "www.other.co.uk",
+			},
+		},
+		{
+			name: "InvalidHostnameError/RegistrableDomain/PublicSuffix",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "co.uk",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameRegistrableDomain("co.uk"),
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "co.uk",
+			},
+		},
+		{
+			name: "InvalidActionError",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "register",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Action("login"),
+			},
+			expected: &InvalidActionError{
+				Action: "register",
+			},
+		},
+		{
+			name: "InvalidActionError/Regexp",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "checkout_abc",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ActionRegexp(regexp.MustCompile(`^checkout_[0-9]+$`)),
+			},
+			expected: &InvalidActionError{
+				Action: "checkout_abc",
+			},
+		},
+		{
+			name: "InvalidScoreError",
+			response: Response{
+				Success:     true,
+				Score:       .4,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Score(.5),
+			},
+			expected: &InvalidScoreError{
+				Score:     .4,
+				Threshold: .5,
+			},
+		},
+		{
+			name: "ZeroScoreError",
+			response: Response{
+				Success:     true,
+				Score:       0,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				RejectZeroScore(),
+			},
+			expected: &ZeroScoreError{},
+		},
+		{
+			name: "InvalidScorePrecisionError",
+			response: Response{
+				Success:     true,
+				Score:       .4999999,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ScoreQuantized(.1),
+			},
+			expected: &InvalidScorePrecisionError{
+				Score: .4999999,
+				Step:  .1,
+			},
+		},
+		{
+			name: "InvalidScorePrecisionError/ZeroStep",
+			response: Response{
+				Success:     true,
+				Score:       .4999999,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ScoreQuantized(0),
+			},
+			expected: &InvalidScorePrecisionError{
+				Score: .4999999,
+				Step:  0,
+			},
+		},
+		{
+			name: "InvalidScorePrecisionError/NegativeStep",
+			response: Response{
+				Success:     true,
+				Score:       .4999999,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ScoreQuantized(-.1),
+			},
+			expected: &InvalidScorePrecisionError{
+				Score: .4999999,
+				Step:  -.1,
+			},
+		},
+		{
+			name: "InvalidScorePrecisionError/InfiniteStep",
+			response: Response{
+				Success:     true,
+				Score:       .4999999,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ScoreQuantized(math.Inf(1)),
+			},
+			expected: &InvalidScorePrecisionError{
+				Score: .4999999,
+				Step:  math.Inf(1),
+			},
+		},
+		{
+			name: "InvalidChallengeTsError",
+			response: Response{
+				Success:     true,
+				Score:       .4,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ChallengeTs(500 * time.Millisecond),
+			},
+			expected: &InvalidChallengeTsError{
+				ChallengeTs: now().Add(-time.Second),
+				Diff:        time.Second,
+			},
+		},
+		{
+			name: "NonUTCChallengeTsError",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: time.Date(2019, 8, 25, 18, 20, 0, 0, time.FixedZone("", 2*60*60)),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ChallengeTsUTC(),
+			},
+			expected: &NonUTCChallengeTsError{
+				ChallengeTs: time.Date(2019, 8, 25, 18, 20, 0, 0, time.FixedZone("", 2*60*60)),
+			},
+		},
+		{
+			name: "Success",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Hostname",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Hostname("niche.com"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Hostname/Multiple",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Hostname("nathanjcochran.com", "niche.com"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Hostname/SNI",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameMatchesSNI(&http.Request{
+					TLS: &tls.ConnectionState{
+						ServerName: "niche.com",
+					},
+				}),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Hostname/CIDR",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameInCIDR(resolver, "10.0.0.0/8", "192.168.0.0/16"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Hostname/RegistrableDomain",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "www.niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameRegistrableDomain("niche.co.uk", "niche.com"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Hostname/RegistrableDomain/MultiLevel",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "a.b.niche.co.uk",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				HostnameRegistrableDomain("niche.co.uk"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Action",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Action("login"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Action/Multiple",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Action("register", "login"),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Action/Regexp",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "checkout_123",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ActionRegexp(regexp.MustCompile(`^checkout_[0-9]+$`)),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/Score",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Score(.5),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/RejectZeroScore",
+			response: Response{
+				Success:     true,
+				Score:       .1,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				RejectZeroScore(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/ScoreQuantized",
+			response: Response{
+				Success:     true,
+				Score:       .7,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ScoreQuantized(.1),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/ScoreQuantized/Zero",
+			response: Response{
+				Success:     true,
+				Score:       0,
+				Action:      "login",
 				ChallengeTs: now().Add(-time.Second),
-				Diff:        time.Second,
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ScoreQuantized(.1),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/ChallengeTs",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ChallengeTs(time.Second),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/ChallengeTsUTC",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ChallengeTsUTC(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/ChallengeTsUTC/ZeroOffset",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.FixedZone("", 0)),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				ChallengeTsUTC(),
+			},
+			expected: nil,
+		},
+		{
+			name: "Success/AllOptions",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{},
+			},
+			criteria: []Criterion{
+				Hostname("niche.com"),
+				Action("login"),
+				Score(.5),
+				ChallengeTs(time.Second),
+			},
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.Verify(testCase.criteria...)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScoreThresholdFunc(t *testing.T) {
+	threshold := .3
+	criterion := ScoreThresholdFunc(func() float64 {
+		return threshold
+	})
+	response := Response{
+		Success: true,
+		Score:   .5,
+	}
+
+	if err := response.Verify(criterion); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	threshold = .7
+	expected := &InvalidScoreError{
+		Score:     .5,
+		Threshold: .7,
+	}
+	if err := response.Verify(criterion); !reflect.DeepEqual(expected, err) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, err)
+	}
+}
+
+func TestHostnameSanitized(t *testing.T) {
+	testCases := []struct {
+		name     string
+		hostname string
+		expected error
+	}{
+		{
+			name:     "Bare",
+			hostname: "niche.com",
+			expected: nil,
+		},
+		{
+			name:     "Scheme",
+			hostname: "https://niche.com",
+			expected: nil,
+		},
+		{
+			name:     "Scheme/Port/Path",
+			hostname: "https://niche.com:8443/login",
+			expected: nil,
+		},
+		{
+			name:     "Port",
+			hostname: "niche.com:443",
+			expected: nil,
+		},
+		{
+			name:     "Mismatch",
+			hostname: "https://nathanjcochran.com",
+			expected: &InvalidHostnameError{
+				Hostname: "niche.com",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:  true,
+				Hostname: "niche.com",
+			}
+			actual := response.Verify(Hostname(testCase.hostname))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHostnameIgnoreWWW(t *testing.T) {
+	testCases := []struct {
+		name      string
+		hostnames []string
+		hostname  string
+		expected  error
+	}{
+		{
+			name:      "Exact",
+			hostnames: []string{"niche.com"},
+			hostname:  "niche.com",
+			expected:  nil,
+		},
+		{
+			name:      "ResponseWWW",
+			hostnames: []string{"niche.com"},
+			hostname:  "www.niche.com",
+			expected:  nil,
+		},
+		{
+			name:      "ConfiguredWWW",
+			hostnames: []string{"www.niche.com"},
+			hostname:  "niche.com",
+			expected:  nil,
+		},
+		{
+			name:      "ConfiguredURL",
+			hostnames: []string{"https://www.niche.com"},
+			hostname:  "niche.com",
+			expected:  nil,
+		},
+		{
+			name:      "OtherSubdomain",
+			hostnames: []string{"niche.com"},
+			hostname:  "api.niche.com",
+			expected: &InvalidHostnameError{
+				Hostname: "api.niche.com",
+			},
+		},
+		{
+			name:      "Mismatch",
+			hostnames: []string{"niche.com", "nathanjcochran.com"},
+			hostname:  "www.example.com",
+			expected: &InvalidHostnameError{
+				Hostname: "www.example.com",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:  true,
+				Hostname: testCase.hostname,
+			}
+			actual := response.Verify(HostnameIgnoreWWW(testCase.hostnames...))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHostnameMatchesOrigin(t *testing.T) {
+	testCases := []struct {
+		name     string
+		origin   []string
+		expected error
+	}{
+		{
+			name:     "Match",
+			origin:   []string{"https://niche.com"},
+			expected: nil,
+		},
+		{
+			name:     "Match/Port",
+			origin:   []string{"https://niche.com:8443"},
+			expected: nil,
+		},
+		{
+			name:   "Mismatch",
+			origin: []string{"https://nathanjcochran.com"},
+			expected: &InvalidHostnameError{
+				Hostname: "niche.com",
+			},
+		},
+		{
+			name:   "Missing",
+			origin: nil,
+			expected: &MissingHostnameError{
+				Source: "Origin header",
+			},
+		},
+		{
+			name:   "Null",
+			origin: []string{"null"},
+			expected: &MissingHostnameError{
+				Source: "Origin header",
+			},
+		},
+		{
+			name:   "Malformed",
+			origin: []string{"https://niche.com:port"},
+			expected: &MissingHostnameError{
+				Source: "Origin header",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := &http.Request{
+				Header: http.Header{},
+			}
+			if testCase.origin != nil {
+				req.Header["Origin"] = testCase.origin
+			}
+			response := Response{
+				Success:  true,
+				Hostname: "niche.com",
+			}
+			actual := response.Verify(HostnameMatchesOrigin(req))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHostnameExpected(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{
+			name:     "Match",
+			expected: "niche.com",
+			err:      nil,
+		},
+		{
+			name:     "Mismatch",
+			expected: "nathanjcochran.com",
+			err: &InvalidHostnameError{
+				Hostname: "niche.com",
+			},
+		},
+		{
+			name:     "Empty",
+			expected: "",
+			err: &MissingHostnameError{
+				Source: "hostname from callback",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			calls := 0
+			criterion := HostnameExpected(func() string {
+				calls++
+				return testCase.expected
+			})
+			response := Response{
+				Success:  true,
+				Hostname: "niche.com",
+			}
+			actual := response.Verify(criterion)
+			if !reflect.DeepEqual(testCase.err, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.err, actual)
+			}
+			if calls != 1 {
+				t.Errorf("Expected 1 call, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestActionMatchesMethod(t *testing.T) {
+	mapping := map[string]string{
+		http.MethodGet:  "get_profile",
+		http.MethodPost: "update_profile",
+	}
+
+	testCases := []struct {
+		name     string
+		method   string
+		action   string
+		expected error
+	}{
+		{
+			name:     "Get",
+			method:   http.MethodGet,
+			action:   "get_profile",
+			expected: nil,
+		},
+		{
+			name:     "Post",
+			method:   http.MethodPost,
+			action:   "update_profile",
+			expected: nil,
+		},
+		{
+			name:   "Get/Mismatch",
+			method: http.MethodGet,
+			action: "update_profile",
+			expected: &InvalidActionError{
+				Action: "update_profile",
+			},
+		},
+		{
+			name:   "Post/Mismatch",
+			method: http.MethodPost,
+			action: "get_profile",
+			expected: &InvalidActionError{
+				Action: "get_profile",
+			},
+		},
+		{
+			name:   "Unmapped",
+			method: http.MethodDelete,
+			action: "update_profile",
+			expected: &InvalidActionError{
+				Action: "update_profile",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(testCase.method, "https://niche.com/profile", nil)
+			response := Response{
+				Success: true,
+				Action:  testCase.action,
+			}
+			actual := response.Verify(ActionMatchesMethod(req, mapping))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestActionNotIn(t *testing.T) {
+	testCases := []struct {
+		name     string
+		previous []string
+		expected error
+	}{
+		{
+			name:     "NoPrevious",
+			previous: nil,
+			expected: nil,
+		},
+		{
+			name:     "New",
+			previous: []string{"step1", "step2"},
+			expected: nil,
+		},
+		{
+			name:     "Replayed",
+			previous: []string{"step1", "step3"},
+			expected: &InvalidActionError{
+				Action: "step3",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success: true,
+				Action:  "step3",
+			}
+			actual := response.Verify(ActionNotIn(testCase.previous...))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScoreByHostname(t *testing.T) {
+	thresholds := map[string]float64{
+		"niche.com":       .7,
+		"Brand.Niche.com": .3,
+	}
+
+	testCases := []struct {
+		name     string
+		hostname string
+		score    float64
+		expected error
+	}{
+		{
+			name:     "Listed/Pass",
+			hostname: "niche.com",
+			score:    .7,
+			expected: nil,
+		},
+		{
+			name:     "Listed/Fail",
+			hostname: "niche.com",
+			score:    .6,
+			expected: &InvalidScoreError{
+				Score:     .6,
+				Threshold: .7,
+			},
+		},
+		{
+			name:     "Listed/CaseInsensitive",
+			hostname: "brand.niche.com",
+			score:    .4,
+			expected: nil,
+		},
+		{
+			name:     "Unlisted/Pass",
+			hostname: "example.com",
+			score:    .5,
+			expected: nil,
+		},
+		{
+			name:     "Unlisted/Fail",
+			hostname: "example.com",
+			score:    .4,
+			expected: &InvalidScoreError{
+				Score:     .4,
+				Threshold: .5,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:  true,
+				Hostname: testCase.hostname,
+				Score:    testCase.score,
+			}
+			actual := response.Verify(ScoreByHostname(thresholds, .5))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScoreVsBaseline(t *testing.T) {
+	testCases := []struct {
+		name     string
+		score    float64
+		expected error
+	}{
+		{
+			name:     "AboveBaseline",
+			score:    .9,
+			expected: nil,
+		},
+		{
+			name:     "SmallDrop",
+			score:    .6,
+			expected: nil,
+		},
+		{
+			name:  "LargeDrop",
+			score: .3,
+			expected: &ScoreDropError{
+				Score:    .3,
+				Baseline: .8,
+				MaxDrop:  .25,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success: true,
+				Score:   testCase.score,
+			}
+			actual := response.Verify(ScoreVsBaseline(.8, .25))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+// percentileEstimatorMock returns the cutoff for each percentile from a fixed
+// table, and records the percentiles requested.
+type percentileEstimatorMock struct {
+	cutoffs map[float64]float64
+	calls   []float64
+}
+
+func (m *percentileEstimatorMock) Percentile(p float64) float64 {
+	m.calls = append(m.calls, p)
+	return m.cutoffs[p]
+}
+
+func TestPercentileScore(t *testing.T) {
+	testCases := []struct {
+		name     string
+		p        float64
+		score    float64
+		expected error
+	}{
+		{
+			name:     "Above",
+			p:        20,
+			score:    .5,
+			expected: nil,
+		},
+		{
+			name:     "Equal",
+			p:        20,
+			score:    .3,
+			expected: nil,
+		},
+		{
+			name:  "Below",
+			p:     20,
+			score: .2,
+			expected: &InvalidScoreError{
+				Score:     .2,
+				Threshold: .3,
+			},
+		},
+		{
+			name:  "HigherPercentile",
+			p:     50,
+			score: .5,
+			expected: &InvalidScoreError{
+				Score:     .5,
+				Threshold: .7,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			estimator := &percentileEstimatorMock{
+				cutoffs: map[float64]float64{
+					20: .3,
+					50: .7,
+				},
+			}
+			response := Response{
+				Success: true,
+				Score:   testCase.score,
+			}
+			actual := response.Verify(PercentileScore(estimator, testCase.p))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+			if expected := []float64{testCase.p}; !reflect.DeepEqual(expected, estimator.calls) {
+				t.Errorf("Expected calls:\n%#v\nActual:\n%#v\n", expected, estimator.calls)
+			}
+		})
+	}
+}
+
+func TestWeightedScore(t *testing.T) {
+	weights := map[string]float64{
+		"checkout": .5,
+		"browse":   2,
+	}
+
+	testCases := []struct {
+		name     string
+		response Response
+		expected error
+	}{
+		{
+			name: "Weighted/Pass",
+			response: Response{
+				Success: true,
+				Score:   .8,
+				Action:  "checkout",
+			},
+			expected: nil,
+		},
+		{
+			name: "Weighted/Fail",
+			response: Response{
+				Success: true,
+				Score:   .6,
+				Action:  "checkout",
+			},
+			expected: &InvalidWeightedScoreError{
+				Score:          .6,
+				Weight:         .5,
+				EffectiveScore: .3,
+				Threshold:      .4,
+			},
+		},
+		{
+			name: "Boosted/Pass",
+			response: Response{
+				Success: true,
+				Score:   .25,
+				Action:  "browse",
+			},
+			expected: nil,
+		},
+		{
+			name: "Default/Fail",
+			response: Response{
+				Success: true,
+				Score:   .3,
+				Action:  "login",
+			},
+			expected: &InvalidWeightedScoreError{
+				Score:          .3,
+				Weight:         1,
+				EffectiveScore: .3,
+				Threshold:      .4,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.Verify(WeightedScore(weights, .4))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChallengeTsWithClock(t *testing.T) {
+	challengeTs := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	current := challengeTs.Add(30 * time.Second)
+	criterion := ChallengeTsWithClock(time.Minute, func() time.Time {
+		return current
+	})
+	response := Response{
+		Success:     true,
+		ChallengeTs: challengeTs,
+	}
+
+	if err := response.Verify(criterion); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	current = challengeTs.Add(90 * time.Second)
+	expected := &InvalidChallengeTsError{
+		ChallengeTs: challengeTs,
+		Diff:        90 * time.Second,
+	}
+	if err := response.Verify(criterion); !reflect.DeepEqual(expected, err) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, err)
+	}
+}
+
+func TestChallengeTsVsServerDate(t *testing.T) {
+	challengeTs := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return challengeTs.Add(time.Hour)
+	}
+
+	testCases := []struct {
+		name       string
+		serverDate time.Time
+		expected   error
+	}{
+		{
+			name:       "ServerDate/Valid",
+			serverDate: challengeTs.Add(30 * time.Second),
+			expected:   nil,
+		},
+		{
+			name:       "ServerDate/Invalid",
+			serverDate: challengeTs.Add(90 * time.Second),
+			expected: &InvalidChallengeTsError{
+				ChallengeTs: challengeTs,
+				Diff:        90 * time.Second,
+			},
+		},
+		{
+			name:       "LocalClock",
+			serverDate: time.Time{},
+			expected: &InvalidChallengeTsError{
+				ChallengeTs: challengeTs,
+				Diff:        time.Hour,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:     true,
+				ChallengeTs: challengeTs,
+				serverDate:  testCase.serverDate,
+			}
+			actual := response.Verify(ChallengeTsVsServerDate(time.Minute))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClockSkewWithin(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	testCases := []struct {
+		name       string
+		serverTime string
+		expected   error
+		err        bool
+	}{
+		{
+			name:       "Absent",
+			serverTime: "",
+			expected:   nil,
+		},
+		{
+			name:       "RFC3339/Within",
+			serverTime: `"2019-08-25T16:20:05Z"`,
+			expected:   nil,
+		},
+		{
+			name:       "RFC3339/Exceeded",
+			serverTime: `"2019-08-25T16:19:30Z"`,
+			expected: &ClockSkewError{
+				ServerTime: time.Date(2019, 8, 25, 16, 19, 30, 0, time.UTC),
+				Skew:       30 * time.Second,
+				Tolerance:  10 * time.Second,
+			},
+		},
+		{
+			name:       "Unix/Within",
+			serverTime: strconv.FormatInt(current.Add(-5*time.Second).Unix(), 10),
+			expected:   nil,
+		},
+		{
+			name:       "Unix/Exceeded",
+			serverTime: strconv.FormatInt(current.Add(time.Minute).Unix(), 10),
+			expected: &ClockSkewError{
+				ServerTime: current.Add(time.Minute),
+				Skew:       time.Minute,
+				Tolerance:  10 * time.Second,
+			},
+		},
+		{
+			name:       "Malformed",
+			serverTime: `"yesterday"`,
+			err:        true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success: true,
+			}
+			if testCase.serverTime != "" {
+				response.Extra = map[string]json.RawMessage{
+					"server_time": json.RawMessage(testCase.serverTime),
+				}
+			}
+			actual := response.Verify(ClockSkewWithin(10 * time.Second))
+			if testCase.err {
+				if actual == nil {
+					t.Error("Expected error, got nil")
+				}
+			} else if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChallengeTsSince(t *testing.T) {
+	received := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		challengeTs time.Time
+		expected    error
+	}{
+		{
+			name:        "Within",
+			challengeTs: received.Add(-30 * time.Second),
+			expected:    nil,
+		},
+		{
+			name:        "Boundary",
+			challengeTs: received.Add(-time.Minute),
+			expected:    nil,
+		},
+		{
+			name:        "Outside",
+			challengeTs: received.Add(-time.Minute - time.Second),
+			expected: &InvalidChallengeTsError{
+				ChallengeTs: received.Add(-time.Minute - time.Second),
+				Diff:        time.Minute + time.Second,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:     true,
+				ChallengeTs: testCase.challengeTs,
+			}
+			actual := response.Verify(ChallengeTsSince(received, time.Minute))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChallengeTsAfterStart(t *testing.T) {
+	start := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original time.Time) {
+		processStart = original
+	}(processStart)
+	processStart = start
+
+	testCases := []struct {
+		name        string
+		challengeTs time.Time
+		expected    error
+	}{
+		{
+			name:        "After",
+			challengeTs: start.Add(time.Second),
+			expected:    nil,
+		},
+		{
+			name:        "Equal",
+			challengeTs: start,
+			expected:    nil,
+		},
+		{
+			name:        "Before",
+			challengeTs: start.Add(-time.Second),
+			expected: &ChallengeTsBeforeStartError{
+				ChallengeTs: start.Add(-time.Second),
+				Start:       start,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:     true,
+				ChallengeTs: testCase.challengeTs,
+			}
+			actual := response.Verify(ChallengeTsAfterStart())
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHostnameNotIP(t *testing.T) {
+	testCases := []struct {
+		name     string
+		hostname string
+		expected error
+	}{
+		{
+			name:     "Domain",
+			hostname: "niche.com",
+			expected: nil,
+		},
+		{
+			name:     "Localhost",
+			hostname: "localhost",
+			expected: nil,
+		},
+		{
+			name:     "NumericSubdomain",
+			hostname: "1.2.3.4.niche.com",
+			expected: nil,
+		},
+		{
+			name:     "IPv4",
+			hostname: "192.168.0.1",
+			expected: &IPHostnameError{
+				Hostname: "192.168.0.1",
+			},
+		},
+		{
+			name:     "IPv6",
+			hostname: "2001:db8::1",
+			expected: &IPHostnameError{
+				Hostname: "2001:db8::1",
+			},
+		},
+		{
+			name:     "IPv6/Brackets",
+			hostname: "[::1]",
+			expected: &IPHostnameError{
+				Hostname: "[::1]",
+			},
+		},
+		{
+			name:     "IPv6/Zone",
+			hostname: "fe80::1%eth0",
+			expected: &IPHostnameError{
+				Hostname: "fe80::1%eth0",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:  true,
+				Hostname: testCase.hostname,
+			}
+			actual := response.Verify(HostnameNotIP())
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChallengeTsInWindow(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	day := func(hour, min, sec int) time.Time {
+		return time.Date(2019, 8, 26, hour, min, sec, 0, est)
+	}
+
+	testCases := []struct {
+		name        string
+		loc         *time.Location
+		start       time.Duration
+		end         time.Duration
+		challengeTs time.Time
+		expected    error
+	}{
+		{
+			name:        "Inside",
+			loc:         est,
+			start:       9 * time.Hour,
+			end:         17 * time.Hour,
+			challengeTs: day(12, 0, 0).UTC(),
+			expected:    nil,
+		},
+		{
+			name:        "Start",
+			loc:         est,
+			start:       9 * time.Hour,
+			end:         17 * time.Hour,
+			challengeTs: day(9, 0, 0).UTC(),
+			expected:    nil,
+		},
+		{
+			name:        "BeforeStart",
+			loc:         est,
+			start:       9 * time.Hour,
+			end:         17 * time.Hour,
+			challengeTs: day(8, 59, 59).UTC(),
+			expected: &ChallengeTsOutsideWindowError{
+				ChallengeTs: day(8, 59, 59),
+				Start:       9 * time.Hour,
+				End:         17 * time.Hour,
+			},
+		},
+		{
+			name:        "BeforeEnd",
+			loc:         est,
+			start:       9 * time.Hour,
+			end:         17 * time.Hour,
+			challengeTs: day(16, 59, 59).UTC(),
+			expected:    nil,
+		},
+		{
+			name:        "End",
+			loc:         est,
+			start:       9 * time.Hour,
+			end:         17 * time.Hour,
+			challengeTs: day(17, 0, 0).UTC(),
+			expected: &ChallengeTsOutsideWindowError{
+				ChallengeTs: day(17, 0, 0),
+				Start:       9 * time.Hour,
+				End:         17 * time.Hour,
+			},
+		},
+		{
+			name:        "SpansMidnight/Late",
+			loc:         est,
+			start:       22 * time.Hour,
+			end:         6 * time.Hour,
+			challengeTs: day(23, 0, 0).UTC(),
+			expected:    nil,
+		},
+		{
+			name:        "SpansMidnight/Early",
+			loc:         est,
+			start:       22 * time.Hour,
+			end:         6 * time.Hour,
+			challengeTs: day(5, 59, 59).UTC(),
+			expected:    nil,
+		},
+		{
+			name:        "SpansMidnight/Outside",
+			loc:         est,
+			start:       22 * time.Hour,
+			end:         6 * time.Hour,
+			challengeTs: day(12, 0, 0).UTC(),
+			expected: &ChallengeTsOutsideWindowError{
+				ChallengeTs: day(12, 0, 0),
+				Start:       22 * time.Hour,
+				End:         6 * time.Hour,
+			},
+		},
+		{
+			name:        "NilLocation",
+			loc:         nil,
+			start:       9 * time.Hour,
+			end:         17 * time.Hour,
+			challengeTs: day(12, 0, 0).UTC(),
+			expected: &ChallengeTsOutsideWindowError{
+				ChallengeTs: day(12, 0, 0).UTC(),
+				Start:       9 * time.Hour,
+				End:         17 * time.Hour,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:     true,
+				ChallengeTs: testCase.challengeTs,
+			}
+			actual := response.Verify(ChallengeTsInWindow(testCase.loc, testCase.start, testCase.end))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScoreIfPresent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected error
+	}{
+		{
+			name:     "Absent",
+			body:     `{"success": true}`,
+			expected: nil,
+		},
+		{
+			name: "PresentZero",
+			body: `{"success": true, "score": 0.0}`,
+			expected: &InvalidScoreError{
+				Score:     0,
+				Threshold: .5,
+			},
+		},
+		{
+			name: "PresentLow",
+			body: `{"success": true, "score": 0.3}`,
+			expected: &InvalidScoreError{
+				Score:     .3,
+				Threshold: .5,
+			},
+		},
+		{
+			name:     "PresentHigh",
+			body:     `{"success": true, "score": 0.7}`,
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := parseResponse([]byte(testCase.body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			actual := response.Verify(ScoreIfPresent(.5))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChallengeTsFormats(t *testing.T) {
+	testCases := []struct {
+		name        string
+		challengeTs string
+		expected    time.Time
+		err         bool
+	}{
+		{
+			name:        "Seconds",
+			challengeTs: "2019-08-25T16:20:00Z",
+			expected:    time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+		},
+		{
+			name:        "Milliseconds",
+			challengeTs: "2019-08-25T16:20:00.123Z",
+			expected:    time.Date(2019, 8, 25, 16, 20, 0, 123000000, time.UTC),
+		},
+		{
+			name:        "Offset",
+			challengeTs: "2019-08-25T16:20:00+00:00",
+			expected:    time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+		},
+		{
+			name:        "Offset/Milliseconds",
+			challengeTs: "2019-08-25T16:20:00.123+00:00",
+			expected:    time.Date(2019, 8, 25, 16, 20, 0, 123000000, time.UTC),
+		},
+		{
+			name:        "NoOffset",
+			challengeTs: "2019-08-25T16:20:00",
+			err:         true,
+		},
+		{
+			name:        "Date",
+			challengeTs: "2019-08-25",
+			err:         true,
+		},
+	}
+
+	parsers := map[string]func(body []byte) (Response, error){
+		"Strict":  parseResponse,
+		"Lenient": parseResponseLenient,
+	}
+	for parserName, parse := range parsers {
+		for _, testCase := range testCases {
+			t.Run(parserName+"/"+testCase.name, func(t *testing.T) {
+				body := fmt.Sprintf(`{"success": true, "challenge_ts": %q}`, testCase.challengeTs)
+				response, err := parse([]byte(body))
+				switch {
+				case testCase.err && err == nil:
+					t.Errorf("Expected error, got %s", response.ChallengeTs)
+				case !testCase.err && err != nil:
+					t.Errorf("Unexpected error: %s", err)
+				case !testCase.err && !testCase.expected.Equal(response.ChallengeTs):
+					t.Errorf("Expected:\n%s\nActual:\n%s\n", testCase.expected, response.ChallengeTs)
+				}
+			})
+		}
+	}
+}
+
+func TestHasScore(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected bool
+	}{
+		{
+			name:     "Absent",
+			body:     `{"success": true}`,
+			expected: false,
+		},
+		{
+			name:     "Null",
+			body:     `{"success": true, "score": null}`,
+			expected: false,
+		},
+		{
+			name:     "Zero",
+			body:     `{"success": true, "score": 0.0}`,
+			expected: true,
+		},
+		{
+			name:     "NonZero",
+			body:     `{"success": true, "score": 0.9}`,
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := parseResponse([]byte(testCase.body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if actual := response.HasScore(); actual != testCase.expected {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHasErrorsIsSuccess(t *testing.T) {
+	testCases := []struct {
+		name      string
+		response  Response
+		hasErrors bool
+		isSuccess bool
+	}{
+		{
+			name: "Success",
+			response: Response{
+				Success:    true,
+				ErrorCodes: []string{},
+			},
+			hasErrors: false,
+			isSuccess: true,
+		},
+		{
+			name: "SuccessWithErrors",
+			response: Response{
+				Success:    true,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+			hasErrors: true,
+			isSuccess: false,
+		},
+		{
+			name: "Failure",
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"invalid-input-response"},
+			},
+			hasErrors: true,
+			isSuccess: false,
+		},
+		{
+			name: "FailureWithoutErrors",
+			response: Response{
+				Success: false,
+			},
+			hasErrors: false,
+			isSuccess: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := testCase.response.HasErrors(); actual != testCase.hasErrors {
+				t.Errorf("Expected HasErrors %t, got %t", testCase.hasErrors, actual)
+			}
+			if actual := testCase.response.IsSuccess(); actual != testCase.isSuccess {
+				t.Errorf("Expected IsSuccess %t, got %t", testCase.isSuccess, actual)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	original := Response{
+		Success:    true,
+		Score:      .9,
+		Action:     "login:user@example.com",
+		Hostname:   "niche.com",
+		ErrorCodes: []string{"timeout-or-duplicate"},
+		Extra: map[string]json.RawMessage{
+			"tenant_id": json.RawMessage(`"niche"`),
+			"region":    json.RawMessage(`"us"`),
+		},
+		present: scoreField,
+	}
+
+	testCases := []struct {
+		name     string
+		fields   []string
+		expected Response
+	}{
+		{
+			name:     "None",
+			fields:   nil,
+			expected: original,
+		},
+		{
+			name:   "Fields",
+			fields: []string{"action", "error-codes", "tenant_id", "unknown"},
+			expected: Response{
+				Success:    true,
+				Score:      .9,
+				Action:     "REDACTED",
+				Hostname:   "niche.com",
+				ErrorCodes: []string{"REDACTED"},
+				Extra: map[string]json.RawMessage{
+					"tenant_id": json.RawMessage(`"REDACTED"`),
+					"region":    json.RawMessage(`"us"`),
+				},
+				present: scoreField,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			before := fmt.Sprintf("%+v", original)
+			actual := original.Redacted(testCase.fields...)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+			if after := fmt.Sprintf("%+v", original); before != after {
+				t.Errorf("Original response modified:\n%s\n%s\n", before, after)
+			}
+		})
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	testCases := []struct {
+		name          string
+		body          string
+		expected      int
+		presentFields []string
+	}{
+		{
+			name:          "V3",
+			body:          `{"success": true, "score": 0.9, "action": "login", "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com"}`,
+			expected:      3,
+			presentFields: []string{"success", "score", "action", "challenge_ts", "hostname"},
+		},
+		{
+			name:          "V3/ErrorCodes",
+			body:          `{"success": true, "score": 0.9, "action": "login", "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com", "error-codes": []}`,
+			expected:      3,
+			presentFields: []string{"success", "score", "action", "challenge_ts", "hostname", "error-codes"},
+		},
+		{
+			name:          "V2",
+			body:          `{"success": true, "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com"}`,
+			expected:      2,
+			presentFields: []string{"success", "challenge_ts", "hostname"},
+		},
+		{
+			name:          "Drift/MissingField",
+			body:          `{"success": true, "score": 0.9, "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com"}`,
+			expected:      0,
+			presentFields: []string{"success", "score", "challenge_ts", "hostname"},
+		},
+		{
+			name:          "Drift/NullField",
+			body:          `{"success": true, "score": 0.9, "action": null, "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com"}`,
+			expected:      0,
+			presentFields: []string{"success", "score", "challenge_ts", "hostname"},
+		},
+		{
+			name:          "Drift/ExtraField",
+			body:          `{"success": true, "score": 0.9, "action": "login", "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com", "risk": "low"}`,
+			expected:      0,
+			presentFields: []string{"success", "score", "action", "challenge_ts", "hostname"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := parseResponse([]byte(testCase.body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if actual := response.SchemaVersion(); actual != testCase.expected {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+			if actual := response.PresentFields(); !reflect.DeepEqual(testCase.presentFields, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.presentFields, actual)
+			}
+
+			var expectedErr error
+			if testCase.expected != 3 {
+				expectedErr = &SchemaMismatchError{
+					Expected:      3,
+					Actual:        testCase.expected,
+					PresentFields: testCase.presentFields,
+				}
+			}
+			if err := response.Verify(ExpectSchema(3)); !reflect.DeepEqual(expectedErr, err) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyOrder(t *testing.T) {
+	// Returns a criterion which records that it was called, and fails if fail
+	// is true
+	criterion := func(calls *[]string, name string, fail bool) Criterion {
+		return func(r *Response) error {
+			*calls = append(*calls, name)
+			if fail {
+				return errors.New(name)
+			}
+			return nil
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		response Response
+		fail     []bool
+		expected []string
+		err      error
+	}{
+		{
+			name: "DefaultCheckFirst",
+			response: Response{
+				Success: false,
+			},
+			fail:     []bool{false, false, false},
+			expected: nil,
+			err:      &VerificationError{},
+		},
+		{
+			name: "InOrder",
+			response: Response{
+				Success: true,
+			},
+			fail:     []bool{false, false, false},
+			expected: []string{"0", "1", "2"},
+			err:      nil,
+		},
+		{
+			name: "StopsOnFailure",
+			response: Response{
+				Success: true,
+			},
+			fail:     []bool{false, true, false},
+			expected: []string{"0", "1"},
+			err:      errors.New("1"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []string
+			var criteria []Criterion
+			for i, fail := range testCase.fail {
+				criteria = append(criteria, criterion(&calls, strconv.Itoa(i), fail))
+			}
+			err := testCase.response.Verify(criteria...)
+			if !reflect.DeepEqual(testCase.expected, calls) {
+				t.Errorf("Expected calls:\n%#v\nActual:\n%#v\n", testCase.expected, calls)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestVerifyLenient(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response Response
+		criteria []Criterion
+		expected error
+	}{
+		{
+			name: "NoCriteria",
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+			expected: nil,
+		},
+		{
+			name: "MaxErrorCodes/Success",
+			response: Response{
+				Success:    true,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+			criteria: []Criterion{
+				MaxErrorCodes(1),
+			},
+			expected: nil,
+		},
+		{
+			name: "MaxErrorCodes/VerificationError",
+			response: Response{
+				Success:    true,
+				ErrorCodes: []string{"timeout-or-duplicate", "bad-request"},
+			},
+			criteria: []Criterion{
+				MaxErrorCodes(1),
+			},
+			expected: &VerificationError{
+				ErrorCodes: []string{"timeout-or-duplicate", "bad-request"},
+			},
+		},
+		{
+			name: "MaxErrorCodes/Unsuccessful",
+			response: Response{
+				Success: false,
+			},
+			criteria: []Criterion{
+				MaxErrorCodes(1),
+			},
+			expected: &VerificationError{},
+		},
+		{
+			name: "MaxErrorCodes/Zero",
+			response: Response{
+				Success:    true,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+			criteria: []Criterion{
+				MaxErrorCodes(0),
+			},
+			expected: &VerificationError{
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+		},
+		{
+			name: "NoSecretErrors/SecretConfigError/Invalid",
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+			criteria: []Criterion{
+				NoSecretErrors(),
+				MaxErrorCodes(0),
+			},
+			expected: &SecretConfigError{
+				ErrorCodes: []string{"invalid-input-secret"},
+			},
+		},
+		{
+			name: "NoSecretErrors/SecretConfigError/Missing",
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"missing-input-response", "missing-input-secret"},
+			},
+			criteria: []Criterion{
+				NoSecretErrors(),
+				MaxErrorCodes(0),
+			},
+			expected: &SecretConfigError{
+				ErrorCodes: []string{"missing-input-response", "missing-input-secret"},
+			},
+		},
+		{
+			name: "NoSecretErrors/VerificationError",
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"invalid-input-response"},
+			},
+			criteria: []Criterion{
+				NoSecretErrors(),
+				MaxErrorCodes(0),
+			},
+			expected: &VerificationError{
+				ErrorCodes: []string{"invalid-input-response"},
+			},
+		},
+		{
+			name: "NoSecretErrors/Unsuccessful",
+			response: Response{
+				Success: false,
+			},
+			criteria: []Criterion{
+				NoSecretErrors(),
+				MaxErrorCodes(0),
+			},
+			expected: &VerificationError{},
+		},
+		{
+			name: "NoSecretErrors/Success",
+			response: Response{
+				Success:    true,
+				ErrorCodes: []string{},
+			},
+			criteria: []Criterion{
+				NoSecretErrors(),
+				MaxErrorCodes(0),
+			},
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.VerifyLenient(testCase.criteria...)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerifyWithWarnings(t *testing.T) {
+	testCases := []struct {
+		name         string
+		response     Response
+		errCriteria  []Criterion
+		warnCriteria []Criterion
+		warnings     []error
+		err          error
+	}{
+		{
+			name: "Error",
+			response: Response{
+				Success:  true,
+				Score:    .2,
+				Hostname: "nathanjcochran.com",
+			},
+			errCriteria: []Criterion{
+				Hostname("niche.com"),
+			},
+			warnCriteria: []Criterion{
+				Score(.5),
+			},
+			warnings: nil,
+			err: &InvalidHostnameError{
+				Hostname: "nathanjcochran.com",
+			},
+		},
+		{
+			name: "Warnings",
+			response: Response{
+				Success:  true,
+				Score:    .2,
+				Action:   "register",
+				Hostname: "niche.com",
+			},
+			errCriteria: []Criterion{
+				Hostname("niche.com"),
+			},
+			warnCriteria: []Criterion{
+				Score(.5),
+				Action("register"),
+				Action("login"),
+			},
+			warnings: []error{
+				&InvalidScoreError{
+					Score:     .2,
+					Threshold: .5,
+				},
+				&InvalidActionError{
+					Action: "register",
+				},
+			},
+			err: nil,
+		},
+		{
+			name: "Success",
+			response: Response{
+				Success:  true,
+				Score:    .7,
+				Hostname: "niche.com",
+			},
+			errCriteria: []Criterion{
+				Hostname("niche.com"),
+			},
+			warnCriteria: []Criterion{
+				Score(.5),
+			},
+			warnings: nil,
+			err:      nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			warnings, err := testCase.response.VerifyWithWarnings(testCase.errCriteria, testCase.warnCriteria)
+			if !reflect.DeepEqual(testCase.warnings, warnings) {
+				t.Errorf("Expected warnings:\n%#v\nActual:\n%#v\n", testCase.warnings, warnings)
+			} else if !reflect.DeepEqual(testCase.err, err) {
+				t.Errorf("Expected error:\n%#v\nActual:\n%#v\n", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	response := Response{
+		Success:  false,
+		Score:    .4,
+		Action:   "login",
+		Hostname: "niche.com",
+	}
+
+	testCases := []struct {
+		name     string
+		criteria []Criterion
+		expected []CriterionResult
+	}{
+		{
+			name:     "NoCriteria",
+			criteria: nil,
+			expected: []CriterionResult{},
+		},
+		{
+			name: "NoShortCircuit",
+			criteria: []Criterion{
+				Score(.5),
+				Hostname("niche.com"),
+				Action("signup"),
+			},
+			expected: []CriterionResult{
+				{
+					Index: 0,
+					Err: &InvalidScoreError{
+						Score:     .4,
+						Threshold: .5,
+					},
+				},
+				{
+					Index: 1,
+					Err:   nil,
+				},
+				{
+					Index: 2,
+					Err: &InvalidActionError{
+						Action: "login",
+					},
+				},
+			},
+		},
+		{
+			name: "Named",
+			criteria: []Criterion{
+				Named("min-score", Score(.5)),
+				Hostname("niche.com"),
+				Named("outer", Named("inner", Action("signup"))),
+			},
+			expected: []CriterionResult{
+				{
+					Index: 0,
+					Name:  "min-score",
+					Err: &InvalidScoreError{
+						Score:     .4,
+						Threshold: .5,
+					},
+				},
+				{
+					Index: 1,
+					Err:   nil,
+				},
+				{
+					Index: 2,
+					Name:  "outer",
+					Err: &InvalidActionError{
+						Action: "login",
+					},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := response.Inspect(testCase.criteria...)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTimed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "Pass",
+			err:      nil,
+			expected: nil,
+		},
+		{
+			name: "Fail",
+			err: &InvalidActionError{
+				Action: "login",
+			},
+			expected: &InvalidActionError{
+				Action: "login",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				names     []string
+				durations []time.Duration
+			)
+			criterion := Timed("slow", func(r *Response) error {
+				time.Sleep(time.Millisecond)
+				return testCase.err
+			}, func(name string, d time.Duration) {
+				names = append(names, name)
+				durations = append(durations, d)
+			})
+
+			actual := criterion(&Response{})
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+			if !reflect.DeepEqual([]string{"slow"}, names) {
+				t.Errorf("Expected names:\n%q\nActual:\n%q\n", []string{"slow"}, names)
+			}
+			if len(durations) != 1 || durations[0] < time.Millisecond {
+				t.Errorf("Expected a single duration of at least %s, got %v", time.Millisecond, durations)
+			}
+		})
+	}
+}
+
+func TestVerificationErrorIsBadRequest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      *VerificationError
+		expected bool
+	}{
+		{
+			name:     "NoErrorCodes",
+			err:      &VerificationError{},
+			expected: false,
+		},
+		{
+			name: "OtherErrorCodes",
+			err: &VerificationError{
+				ErrorCodes: []string{"invalid-input-response"},
+			},
+			expected: false,
+		},
+		{
+			name: "BadRequest",
+			err: &VerificationError{
+				ErrorCodes: []string{"invalid-input-response", "bad-request"},
+			},
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := testCase.err.IsBadRequest(); actual != testCase.expected {
+				t.Errorf("Expected: %t, Actual: %t", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerifyMany(t *testing.T) {
+	testCases := []struct {
+		name            string
+		responses       []Response
+		expected        []error
+		expectedSummary VerifySummary
+	}{
+		{
+			name:            "Empty",
+			responses:       nil,
+			expected:        []error{},
+			expectedSummary: VerifySummary{},
+		},
+		{
+			name: "Mixed",
+			responses: []Response{
+				{Success: true, Score: .9, Action: "login"},
+				{Success: false, ErrorCodes: []string{"timeout-or-duplicate"}},
+				{Success: true, Score: .1, Action: "login"},
+				{Success: true, Score: .7, Action: "login"},
+			},
+			expected: []error{
+				nil,
+				&VerificationError{
+					ErrorCodes: []string{"timeout-or-duplicate"},
+				},
+				&InvalidScoreError{
+					Score:     .1,
+					Threshold: .5,
+				},
+				nil,
+			},
+			expectedSummary: VerifySummary{
+				Passed: 2,
+				Failed: 2,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := VerifyMany(testCase.responses, Action("login"), Score(.5))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+			if summary := Summarize(actual); summary != testCase.expectedSummary {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expectedSummary, summary)
+			}
+		})
+	}
+}
+
+func TestAggregateErrorCodes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		responses []Response
+		expected  map[string]int
+	}{
+		{
+			name:      "Empty",
+			responses: nil,
+			expected:  map[string]int{},
+		},
+		{
+			name: "NoErrorCodes",
+			responses: []Response{
+				{Success: true},
 			},
+			expected: map[string]int{},
 		},
 		{
-			name: "Success",
-			response: Response{
-				Success:     true,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
+			name: "Overlapping",
+			responses: []Response{
+				{ErrorCodes: []string{"timeout-or-duplicate"}},
+				{ErrorCodes: []string{"invalid-input-response", "timeout-or-duplicate"}},
+				{Success: true},
+				{ErrorCodes: []string{"invalid-input-secret", "invalid-input-response"}},
+				{ErrorCodes: []string{"timeout-or-duplicate"}},
+			},
+			expected: map[string]int{
+				"timeout-or-duplicate":   3,
+				"invalid-input-response": 2,
+				"invalid-input-secret":   1,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := AggregateErrorCodes(testCase.responses)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	var (
+		pass = Response{Success: true, Score: .9}
+		fail = Response{Success: true, Score: .1}
+		err  = &InvalidScoreError{Score: .1, Threshold: .5}
+	)
+
+	testCases := []struct {
+		name      string
+		n         int
+		responses []Response
+		expected  error
+	}{
+		{
+			name:      "Zero/Empty",
+			n:         0,
+			responses: nil,
+			expected:  nil,
+		},
+		{
+			name:      "One/Empty",
+			n:         1,
+			responses: nil,
+			expected: &QuorumError{
+				Required: 1,
+				Passed:   0,
+				Errs:     []error{},
+			},
+		},
+		{
+			name:      "Met",
+			n:         2,
+			responses: []Response{pass, fail, pass},
+			expected:  nil,
+		},
+		{
+			name:      "Exceeded",
+			n:         2,
+			responses: []Response{pass, pass, pass},
+			expected:  nil,
+		},
+		{
+			name:      "NotMet",
+			n:         2,
+			responses: []Response{fail, pass, fail},
+			expected: &QuorumError{
+				Required: 2,
+				Passed:   1,
+				Errs:     []error{err, nil, err},
+			},
+		},
+		{
+			name:      "MoreThanResponses",
+			n:         4,
+			responses: []Response{pass, pass, pass},
+			expected: &QuorumError{
+				Required: 4,
+				Passed:   3,
+				Errs:     []error{nil, nil, nil},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := Quorum(testCase.n, testCase.responses, Score(.5))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestConsistentAcross(t *testing.T) {
+	testCases := []struct {
+		name      string
+		responses []Response
+		expected  error
+	}{
+		{
+			name:      "Empty",
+			responses: nil,
+			expected:  nil,
+		},
+		{
+			name: "Consistent",
+			responses: []Response{
+				{Hostname: "niche.com", Action: "signup"},
+				{Hostname: "niche.com", Action: "signup"},
+				{Hostname: "niche.com", Action: "signup"},
 			},
 			expected: nil,
 		},
 		{
-			name: "Success/Hostname",
-			response: Response{
-				Success:     true,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
+			name: "InconsistentHostname",
+			responses: []Response{
+				{Hostname: "niche.com", Action: "signup"},
+				{Hostname: "niche.com", Action: "signup"},
+				{Hostname: "nathanjcochran.com", Action: "signup"},
 			},
-			criteria: []Criterion{
-				Hostname("niche.com"),
+			expected: &InconsistentResponseError{
+				Index:    2,
+				Field:    "hostname",
+				Expected: "niche.com",
+				Actual:   "nathanjcochran.com",
+			},
+		},
+		{
+			name: "InconsistentAction",
+			responses: []Response{
+				{Hostname: "niche.com", Action: "signup"},
+				{Hostname: "niche.com", Action: "login"},
+			},
+			expected: &InconsistentResponseError{
+				Index:    1,
+				Field:    "action",
+				Expected: "signup",
+				Actual:   "login",
 			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := ConsistentAcross(testCase.responses)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtra(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected map[string]json.RawMessage
+	}{
+		{
+			name:     "None",
+			body:     `{"success": true, "score": 0.9, "action": "login"}`,
 			expected: nil,
 		},
 		{
-			name: "Success/Hostname/Multiple",
-			response: Response{
-				Success:     true,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
-				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
+			name: "Some",
+			body: `{"success": true, "tenant_id": "niche", "region": {"id": 1}}`,
+			expected: map[string]json.RawMessage{
+				"tenant_id": json.RawMessage(`"niche"`),
+				"region":    json.RawMessage(`{"id": 1}`),
 			},
-			criteria: []Criterion{
-				Hostname("nathanjcochran.com", "niche.com"),
+		},
+		{
+			name:     "CaseInsensitive",
+			body:     `{"Success": true, "HOSTNAME": "niche.com"}`,
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := parseResponse([]byte(testCase.body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(testCase.expected, response.Extra) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, response.Extra)
+			}
+		})
+	}
+}
+
+func TestExtraEquals(t *testing.T) {
+	response := Response{
+		Success: true,
+		Extra: map[string]json.RawMessage{
+			"tenant_id": json.RawMessage(`"niche"`),
+			"shard":     json.RawMessage(`42`),
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		key      string
+		value    string
+		expected error
+	}{
+		{
+			name:     "String/Match",
+			key:      "tenant_id",
+			value:    "niche",
+			expected: nil,
+		},
+		{
+			name:  "String/Mismatch",
+			key:   "tenant_id",
+			value: "other",
+			expected: &MismatchError{
+				Field:    "tenant_id",
+				Expected: "other",
+				Actual:   "niche",
 			},
+		},
+		{
+			name:     "Number/Match",
+			key:      "shard",
+			value:    "42",
 			expected: nil,
 		},
 		{
-			name: "Success/Action",
+			name:  "Missing",
+			key:   "region",
+			value: "us",
+			expected: &MissingFieldError{
+				Field: "region",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := response.Verify(ExtraEquals(testCase.key, testCase.value))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithExtra(t *testing.T) {
+	var (
+		errMissingReasons = errors.New("missing reasons")
+		errLowConfidence  = errors.New("low confidence")
+	)
+	criterion := WithExtra(func(extra map[string]json.RawMessage) error {
+		raw, ok := extra["reasons"]
+		if !ok {
+			return errMissingReasons
+		}
+		var reasons []string
+		if err := json.Unmarshal(raw, &reasons); err != nil {
+			return err
+		}
+		for _, reason := range reasons {
+			if reason == "LOW_CONFIDENCE_SCORE" {
+				return errLowConfidence
+			}
+		}
+		return nil
+	})
+
+	testCases := []struct {
+		name     string
+		extra    map[string]json.RawMessage
+		expected error
+	}{
+		{
+			name: "Pass",
+			extra: map[string]json.RawMessage{
+				"reasons": json.RawMessage(`["AUTOMATION"]`),
+			},
+			expected: nil,
+		},
+		{
+			name: "Fail",
+			extra: map[string]json.RawMessage{
+				"reasons": json.RawMessage(`["AUTOMATION", "LOW_CONFIDENCE_SCORE"]`),
+			},
+			expected: errLowConfidence,
+		},
+		{
+			name:     "NoExtra",
+			extra:    nil,
+			expected: errMissingReasons,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success: true,
+				Extra:   testCase.extra,
+			}
+			actual := response.Verify(criterion)
+			if testCase.expected != actual {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	expected := Response{
+		Success:     true,
+		Score:       .9,
+		Action:      "login",
+		ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+		Hostname:    "niche.com",
+		ErrorCodes:  nil,
+	}
+
+	testCases := []struct {
+		name     string
+		response Response
+		ignore   []string
+		expected error
+	}{
+		{
+			name: "Match",
 			response: Response{
 				Success:     true,
-				Score:       .5,
+				Score:       .9,
 				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
 				Hostname:    "niche.com",
 				ErrorCodes:  []string{},
 			},
-			criteria: []Criterion{
-				Action("login"),
-			},
 			expected: nil,
 		},
 		{
-			name: "Success/Action/Multiple",
+			name: "Match/Ignore",
 			response: Response{
 				Success:     true,
-				Score:       .5,
+				Score:       .9,
 				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
+				ChallengeTs: time.Date(2019, 8, 26, 16, 20, 0, 0, time.UTC),
 				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
-			},
-			criteria: []Criterion{
-				Action("register", "login"),
 			},
+			ignore:   []string{"challenge_ts"},
 			expected: nil,
 		},
 		{
-			name: "Success/Score",
+			name: "Mismatch/ChallengeTs",
 			response: Response{
 				Success:     true,
-				Score:       .5,
+				Score:       .9,
 				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
+				ChallengeTs: time.Date(2019, 8, 26, 16, 20, 0, 0, time.UTC),
 				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
 			},
-			criteria: []Criterion{
-				Score(.5),
+			expected: &MismatchError{
+				Field:    "challenge_ts",
+				Expected: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+				Actual:   time.Date(2019, 8, 26, 16, 20, 0, 0, time.UTC),
 			},
-			expected: nil,
 		},
 		{
-			name: "Success/ChallengeTs",
+			name: "Mismatch/First",
 			response: Response{
 				Success:     true,
-				Score:       .5,
-				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
+				Score:       .1,
+				Action:      "register",
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
 				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
 			},
-			criteria: []Criterion{
-				ChallengeTs(time.Second),
+			expected: &MismatchError{
+				Field:    "score",
+				Expected: .9,
+				Actual:   .1,
 			},
-			expected: nil,
 		},
 		{
-			name: "Success/AllOptions",
+			name: "Mismatch/ErrorCodes",
 			response: Response{
 				Success:     true,
-				Score:       .5,
+				Score:       .9,
 				Action:      "login",
-				ChallengeTs: now().Add(-time.Second),
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
 				Hostname:    "niche.com",
-				ErrorCodes:  []string{},
+				ErrorCodes:  []string{"timeout-or-duplicate"},
 			},
-			criteria: []Criterion{
-				Hostname("niche.com"),
-				Action("login"),
-				Score(.5),
-				ChallengeTs(time.Second),
+			expected: &MismatchError{
+				Field:    "error-codes",
+				Expected: []string(nil),
+				Actual:   []string{"timeout-or-duplicate"},
 			},
-			expected: nil,
 		},
 	}
 
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
-			actual := testCase.response.Verify(testCase.criteria...)
+			actual := testCase.response.VerifyLenient(Matches(expected, testCase.ignore...))
 			if !reflect.DeepEqual(testCase.expected, actual) {
 				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
 			}
 		})
 	}
 }
+
+func TestScoreErrorMessages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name: "InvalidScore",
+			err: &InvalidScoreError{
+				Score:     .4,
+				Threshold: .5,
+			},
+			expected: "invalid reCAPTCHA: invalid score: 0.40 (threshold: 0.50)",
+		},
+		{
+			name: "InvalidScore/Rounded",
+			err: &InvalidScoreError{
+				Score:     .4,
+				Threshold: .456,
+			},
+			expected: "invalid reCAPTCHA: invalid score: 0.40 (threshold: 0.46)",
+		},
+		{
+			name: "InvalidWeightedScore",
+			err: &InvalidWeightedScoreError{
+				Score:          .6,
+				Weight:         .75,
+				EffectiveScore: .45,
+				Threshold:      .5,
+			},
+			expected: "invalid reCAPTCHA: invalid effective score: 0.45 (score: 0.60, weight: 0.75, threshold: 0.50)",
+		},
+		{
+			name: "ScoreDrop",
+			err: &ScoreDropError{
+				Score:    .3,
+				Baseline: .9,
+				MaxDrop:  .4,
+			},
+			expected: "invalid reCAPTCHA: score 0.30 dropped more than 0.40 below baseline 0.90",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := testCase.err.Error(); actual != testCase.expected {
+				t.Errorf("Expected:\n%s\nActual:\n%s\n", testCase.expected, actual)
+			}
+		})
+	}
+}
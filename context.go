@@ -0,0 +1,148 @@
+package recaptcha
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// contextKey is the type of the keys used to store values in a
+// context.Context, preventing collisions with keys defined in other packages.
+type contextKey int
+
+const (
+	expectedActionsKey contextKey = iota
+	receivedTimeKey
+	requestIDKey
+)
+
+// WithExpectedActions returns a copy of ctx carrying the actions expected for
+// the current request, e.g. as set by router middleware from the matched
+// route. The actions are read by the ActionFromContext criterion.
+func WithExpectedActions(ctx context.Context, actions ...string) context.Context {
+	return context.WithValue(ctx, expectedActionsKey, actions)
+}
+
+// ExpectedActions returns the actions stored in ctx by WithExpectedActions,
+// or nil if there are none.
+func ExpectedActions(ctx context.Context) []string {
+	actions, _ := ctx.Value(expectedActionsKey).([]string)
+	return actions
+}
+
+// ActionFromContext is an optional verification criterion which ensures that
+// the website action associated with the reCAPTCHA matches one of the actions
+// stored in ctx by WithExpectedActions. If ctx contains no expected actions,
+// every action is rejected. Returns *InvalidActionError if the action is not
+// correct.
+func ActionFromContext(ctx context.Context) Criterion {
+	return Action(ExpectedActions(ctx)...)
+}
+
+// WithReceivedTime returns a copy of ctx carrying the time at which the current
+// request was received, e.g. as stamped by middleware before any other
+// processing. The time is read by the ChallengeTsSinceReceived criterion.
+func WithReceivedTime(ctx context.Context, received time.Time) context.Context {
+	return context.WithValue(ctx, receivedTimeKey, received)
+}
+
+// ReceivedTime returns the time stored in ctx by WithReceivedTime, and whether
+// there was one.
+func ReceivedTime(ctx context.Context) (time.Time, bool) {
+	received, ok := ctx.Value(receivedTimeKey).(time.Time)
+	return received, ok
+}
+
+// ChallengeTsSinceReceived is like ChallengeTsSince, using the time stored in
+// ctx by WithReceivedTime. If ctx contains no received time, the current time
+// is used instead, as in ChallengeTs. Returns *InvalidChallengeTsError if the
+// challenge timestamp is outside the valid window.
+func ChallengeTsSinceReceived(ctx context.Context, window time.Duration) Criterion {
+	if received, ok := ReceivedTime(ctx); ok {
+		return ChallengeTsSince(received, window)
+	}
+	return ChallengeTs(window)
+}
+
+// WithRequestID returns a copy of ctx carrying a client-side ID for the current
+// verification request, for correlating logs (e.g. when filing a support ticket
+// about scoring). Since the context passed to Fetch is passed on to the
+// Observer, the ID can be retrieved there via RequestID, and it is included in
+// the events written via SetEventWriter.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the ID stored in ctx by WithRequestID, or an empty string
+// if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// CriterionCtx is a context-aware variant of Criterion, for criteria which may
+// block (e.g. on network requests) and should be interrupted when the context
+// is done. It can be applied via VerifyContext. Use AdaptCriterion to convert a
+// plain Criterion.
+type CriterionCtx func(ctx context.Context, r *Response) error
+
+// AdaptCriterion converts a plain Criterion into a CriterionCtx which ignores
+// its context. VerifyContext still stops waiting for it once the context is
+// done.
+func AdaptCriterion(criterion Criterion) CriterionCtx {
+	return func(_ context.Context, r *Response) error {
+		return criterion(r)
+	}
+}
+
+// AdaptCriteria converts each of the provided plain criteria via
+// AdaptCriterion, e.g. for passing to VerifyContext alongside context-aware
+// criteria.
+func AdaptCriteria(criteria ...Criterion) []CriterionCtx {
+	adapted := make([]CriterionCtx, len(criteria))
+	for i, criterion := range criteria {
+		adapted[i] = AdaptCriterion(criterion)
+	}
+	return adapted
+}
+
+// VerifyContext is like Verify, but applies context-aware criteria, and bounds
+// the whole verification by the provided context. Each criterion is passed
+// the context, and VerifyContext returns as soon as the context is done, even
+// if a criterion ignores it, in which case the criterion is left to finish in
+// the background. Such an abandoned criterion may still be reading the
+// response, so the response must not be modified after VerifyContext returns
+// a context error. Network-backed criteria should therefore be context-aware
+// (e.g. HostnameInCIDRCtx or RemotePolicyCtx) rather than adapted via
+// AdaptCriterion, so that they stop promptly. Returns the context's error,
+// wrapped, if it is done before every criterion has passed.
+func (r *Response) VerifyContext(ctx context.Context, criteria ...CriterionCtx) error {
+	if !r.IsSuccess() {
+		return &VerificationError{
+			ErrorCodes: r.ErrorCodes,
+		}
+	}
+
+	for i, criterion := range criteria {
+		if err := ctx.Err(); err != nil {
+			return xerrors.Errorf("error applying criterion %d: %w", i, err)
+		}
+
+		// Buffered, so that an abandoned criterion does not block forever
+		result := make(chan error, 1)
+		go func(criterion CriterionCtx) {
+			result <- criterion(ctx, r)
+		}(criterion)
+
+		select {
+		case err := <-result:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return xerrors.Errorf("error applying criterion %d: %w", i, ctx.Err())
+		}
+	}
+	return nil
+}
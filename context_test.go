@@ -0,0 +1,298 @@
+package recaptcha
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestExpectedActions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected []string
+	}{
+		{
+			name:     "Unset",
+			ctx:      context.Background(),
+			expected: nil,
+		},
+		{
+			name:     "Set",
+			ctx:      WithExpectedActions(context.Background(), "login", "signup"),
+			expected: []string{"login", "signup"},
+		},
+		{
+			name: "Overridden",
+			ctx: WithExpectedActions(
+				WithExpectedActions(context.Background(), "login"),
+				"signup",
+			),
+			expected: []string{"signup"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := ExpectedActions(testCase.ctx)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestActionFromContext(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		response Response
+		expected error
+	}{
+		{
+			name: "Match",
+			ctx:  WithExpectedActions(context.Background(), "login", "signup"),
+			response: Response{
+				Action: "signup",
+			},
+			expected: nil,
+		},
+		{
+			name: "Mismatch",
+			ctx:  WithExpectedActions(context.Background(), "login"),
+			response: Response{
+				Action: "signup",
+			},
+			expected: &InvalidActionError{
+				Action: "signup",
+			},
+		},
+		{
+			name: "Unset",
+			ctx:  context.Background(),
+			response: Response{
+				Action: "login",
+			},
+			expected: &InvalidActionError{
+				Action: "login",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := ActionFromContext(testCase.ctx)(&testCase.response)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChallengeTsSinceReceived(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 25, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	received := time.Date(2019, 8, 25, 16, 20, 30, 0, time.UTC)
+	challengeTs := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected error
+	}{
+		{
+			name:     "Received",
+			ctx:      WithReceivedTime(context.Background(), received),
+			expected: nil,
+		},
+		{
+			name: "Unset",
+			ctx:  context.Background(),
+			expected: &InvalidChallengeTsError{
+				ChallengeTs: challengeTs,
+				Diff:        5 * time.Minute,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success:     true,
+				ChallengeTs: challengeTs,
+			}
+			actual := response.Verify(ChallengeTsSinceReceived(testCase.ctx, time.Minute))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReceivedTime(t *testing.T) {
+	received := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	if actual, ok := ReceivedTime(context.Background()); ok {
+		t.Errorf("Expected no received time, got %s", actual)
+	}
+	actual, ok := ReceivedTime(WithReceivedTime(context.Background(), received))
+	if !ok || !actual.Equal(received) {
+		t.Errorf("Expected received time %s, got %s (found: %t)", received, actual, ok)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{
+			name:     "Unset",
+			ctx:      context.Background(),
+			expected: "",
+		},
+		{
+			name:     "Set",
+			ctx:      WithRequestID(context.Background(), "req-123"),
+			expected: "req-123",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				calls    int
+				observed string
+			)
+			client := NewClient("secret",
+				SetHTTPClient(&httpClientMock{
+					doStub: func(req *http.Request) (*http.Response, error) {
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+						}, nil
+					},
+				}),
+				SetObserver(func(ctx context.Context, r Response, err error) {
+					calls++
+					observed = RequestID(ctx)
+				}),
+			)
+			client.Fetch(testCase.ctx, "token", "")
+
+			if calls != 1 {
+				t.Errorf("Expected 1 call, got %d", calls)
+			} else if observed != testCase.expected {
+				t.Errorf("Expected request ID %q, got %q", testCase.expected, observed)
+			}
+		})
+	}
+}
+
+func TestVerifyContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	criterionErr := &InvalidActionError{Action: "login"}
+	blocking := func(ctx context.Context, r *Response) error {
+		<-ctx.Done()
+		return nil
+	}
+	unresponsive := make(chan struct{})
+	defer close(unresponsive)
+
+	testCases := []struct {
+		name     string
+		response Response
+		timeout  time.Duration
+		ctx      context.Context
+		criteria []CriterionCtx
+		expected error
+	}{
+		{
+			name:     "Pass",
+			response: Response{Success: true, Action: "login"},
+			ctx:      context.Background(),
+			criteria: AdaptCriteria(Action("login")),
+			expected: nil,
+		},
+		{
+			name:     "Unsuccessful",
+			response: Response{ErrorCodes: []string{"invalid-input-response"}},
+			ctx:      context.Background(),
+			expected: &VerificationError{ErrorCodes: []string{"invalid-input-response"}},
+		},
+		{
+			name:     "CriterionFailure",
+			response: Response{Success: true, Action: "register"},
+			ctx:      context.Background(),
+			criteria: []CriterionCtx{
+				func(ctx context.Context, r *Response) error {
+					return criterionErr
+				},
+			},
+			expected: criterionErr,
+		},
+		{
+			name:     "AlreadyCanceled",
+			response: Response{Success: true},
+			ctx:      canceled,
+			criteria: []CriterionCtx{blocking},
+			expected: context.Canceled,
+		},
+		{
+			name:     "DeadlineExceeded",
+			response: Response{Success: true},
+			ctx:      context.Background(),
+			timeout:  10 * time.Millisecond,
+			criteria: []CriterionCtx{blocking},
+			expected: context.DeadlineExceeded,
+		},
+		{
+			name:     "AdaptedCriterionIgnoresContext",
+			response: Response{Success: true},
+			ctx:      context.Background(),
+			timeout:  10 * time.Millisecond,
+			criteria: AdaptCriteria(func(r *Response) error {
+				<-unresponsive
+				return nil
+			}),
+			expected: context.DeadlineExceeded,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := testCase.ctx
+			if testCase.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, testCase.timeout)
+				defer cancel()
+			}
+
+			actual := testCase.response.VerifyContext(ctx, testCase.criteria...)
+			switch testCase.expected {
+			case context.Canceled, context.DeadlineExceeded:
+				if !xerrors.Is(actual, testCase.expected) {
+					t.Errorf("Expected error wrapping %v, got %#v", testCase.expected, actual)
+				}
+			default:
+				if !reflect.DeepEqual(testCase.expected, actual) {
+					t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+				}
+			}
+		})
+	}
+}
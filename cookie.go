@@ -0,0 +1,88 @@
+package recaptcha
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignAction returns a value for a cookie which binds the provided action to
+// the provided session (e.g. the ID of the user's server-side session) until
+// the provided expiry time, for use with the ActionFromCookie criterion. The
+// value consists of the action, the expiry time as a Unix timestamp, and an
+// HMAC-SHA256 signature of the action, session ID, and expiry time using the
+// provided key, separated by periods. The session ID is not included in the
+// value, so the cookie is only accepted alongside the same session, and it is
+// rejected once it expires. The action is not encrypted, so it must not be
+// secret.
+func SignAction(action, sessionID string, expires time.Time, key []byte) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	signature := signAction(action, sessionID, expiry, key)
+	return action + "." + expiry + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// signAction returns the HMAC-SHA256 signature of the action, session ID, and
+// formatted expiry time using the key.
+func signAction(action, sessionID, expiry string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(action))
+	mac.Write([]byte{0})
+	mac.Write([]byte(sessionID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(expiry))
+	return mac.Sum(nil)
+}
+
+// ActionFromCookie is an optional verification criterion which ensures that
+// the website action associated with the reCAPTCHA matches the action bound to
+// the provided incoming request by the cookie with the provided name, whose
+// value must have been produced by SignAction using the same session ID and
+// key, and must not have expired. This ties verification to server-issued
+// state, so that a token obtained for one action cannot be submitted in a flow
+// the server started for another, and a cookie issued to one session cannot be
+// replayed by another. Returns *MissingActionCookieError if the request has no
+// such cookie, *InvalidActionCookieError if its value is malformed or its
+// signature is not valid (including if it was signed for another session),
+// *ExpiredActionCookieError if it has expired, or *InvalidActionError if the
+// action is not correct.
+func ActionFromCookie(req *http.Request, name, sessionID string, key []byte) Criterion {
+	return func(r *Response) error {
+		cookie, err := req.Cookie(name)
+		if err != nil {
+			return &MissingActionCookieError{
+				Name: name,
+			}
+		}
+
+		parts := strings.Split(cookie.Value, ".")
+		if len(parts) != 3 {
+			return &InvalidActionCookieError{
+				Name: name,
+			}
+		}
+		action, expiry := parts[0], parts[1]
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil || !hmac.Equal(signature, signAction(action, sessionID, expiry, key)) {
+			return &InvalidActionCookieError{
+				Name: name,
+			}
+		}
+		expires, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return &InvalidActionCookieError{
+				Name: name,
+			}
+		}
+		if expiresAt := time.Unix(expires, 0); !now().Before(expiresAt) {
+			return &ExpiredActionCookieError{
+				Name:    name,
+				Expires: expiresAt,
+			}
+		}
+		return Action(action)(r)
+	}
+}
@@ -0,0 +1,167 @@
+package recaptcha
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActionFromCookie(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	var (
+		key     = []byte("key")
+		expires = current.Add(time.Hour)
+		valid   = SignAction("login", "session", expires, key)
+	)
+
+	testCases := []struct {
+		name     string
+		cookie   *http.Cookie
+		action   string
+		expected error
+	}{
+		{
+			name: "Valid",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: valid,
+			},
+			action:   "login",
+			expected: nil,
+		},
+		{
+			name: "WrongAction",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: valid,
+			},
+			action: "signup",
+			expected: &InvalidActionError{
+				Action: "signup",
+			},
+		},
+		{
+			name:   "Missing",
+			cookie: nil,
+			action: "login",
+			expected: &MissingActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "WrongName",
+			cookie: &http.Cookie{
+				Name:  "other",
+				Value: valid,
+			},
+			action: "login",
+			expected: &MissingActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "Unsigned",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: "login",
+			},
+			action: "login",
+			expected: &InvalidActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "MalformedSignature",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: "login.1566753600.!!!",
+			},
+			action: "login",
+			expected: &InvalidActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "TamperedAction",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: "signup" + valid[len("login"):],
+			},
+			action: "signup",
+			expected: &InvalidActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "TamperedExpiry",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: "login.9999999999" + valid[len("login.1566756000"):],
+			},
+			action: "login",
+			expected: &InvalidActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "WrongKey",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: SignAction("login", "session", expires, []byte("other")),
+			},
+			action: "login",
+			expected: &InvalidActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "OtherSession",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: SignAction("login", "other", expires, key),
+			},
+			action: "login",
+			expected: &InvalidActionCookieError{
+				Name: "recaptcha_action",
+			},
+		},
+		{
+			name: "Expired",
+			cookie: &http.Cookie{
+				Name:  "recaptcha_action",
+				Value: SignAction("login", "session", current, key),
+			},
+			action: "login",
+			expected: &ExpiredActionCookieError{
+				Name:    "recaptcha_action",
+				Expires: time.Unix(current.Unix(), 0),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://niche.com/login", nil)
+			if testCase.cookie != nil {
+				req.AddCookie(testCase.cookie)
+			}
+			response := Response{
+				Success: true,
+				Action:  testCase.action,
+			}
+			actual := response.Verify(ActionFromCookie(req, "recaptcha_action", "session", key))
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
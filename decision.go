@@ -0,0 +1,146 @@
+package recaptcha
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// Thresholds applied to the blended score by Decide.
+const (
+	// PassThreshold is the minimum blended score for a Pass recommendation.
+	PassThreshold = 0.7
+	// StepUpThreshold is the minimum blended score for a StepUp
+	// recommendation. Lower scores result in a Block recommendation.
+	StepUpThreshold = 0.3
+)
+
+// Recommendation is the course of action recommended by Decide.
+type Recommendation int
+
+// Possible recommendations, in order of increasing severity.
+const (
+	// Pass indicates that the request should be allowed.
+	Pass Recommendation = iota
+	// StepUp indicates that additional verification (e.g. a reCAPTCHA v2
+	// challenge or two-factor authentication) should be required.
+	StepUp
+	// Block indicates that the request should be rejected.
+	Block
+)
+
+func (r Recommendation) String() string {
+	switch r {
+	case Pass:
+		return "pass"
+	case StepUp:
+		return "step-up"
+	case Block:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// Signal is an external risk signal which can be blended with the reCAPTCHA
+// score by Decide. Like the reCAPTCHA score, its Score should range from 0.0
+// (very likely a bot) to 1.0 (very likely a human).
+type Signal struct {
+	Name   string
+	Score  float64
+	Weight float64
+}
+
+// Decision is the result of Decide.
+type Decision struct {
+	// Score is the weighted average of the reCAPTCHA score and the external
+	// signals.
+	Score float64
+	// Recommendation is derived from Score, using PassThreshold and
+	// StepUpThreshold.
+	Recommendation Recommendation
+}
+
+// Decide blends the response's score (which has a weight of 1) with the scores
+// of the provided external signals, weighted by their Weight (signals with a
+// non-positive weight are ignored), and recommends a course of action based on
+// the result. Decide does not verify the response, so Verify should be called
+// first.
+func Decide(resp Response, extra ...Signal) Decision {
+	total, weights := resp.Score, 1.0
+	for _, signal := range extra {
+		if signal.Weight <= 0 {
+			continue
+		}
+		total += signal.Score * signal.Weight
+		weights += signal.Weight
+	}
+
+	decision := Decision{
+		Score: total / weights,
+	}
+	switch {
+	case decision.Score >= PassThreshold:
+		decision.Recommendation = Pass
+	case decision.Score >= StepUpThreshold:
+		decision.Recommendation = StepUp
+	default:
+		decision.Recommendation = Block
+	}
+	return decision
+}
+
+// Escalation maps the error returned by a failing criterion to a
+// Recommendation, as used by EvaluateWith.
+type Escalation func(err error) Recommendation
+
+// DefaultEscalation is the Escalation used by Evaluate. Soft failures, which
+// a legitimate user might plausibly cause (i.e. a low score, a drop from the
+// user's baseline score, an expired challenge, or a challenge outside the
+// window given to ChallengeTsInWindow), result in a StepUp recommendation. All
+// other failures (e.g. a wrong hostname or action, or a zero score rejected by
+// RejectZeroScore) result in a Block recommendation.
+func DefaultEscalation(err error) Recommendation {
+	var (
+		scoreErr         *InvalidScoreError
+		weightedScoreErr *InvalidWeightedScoreError
+		scoreDropErr     *ScoreDropError
+		challengeTsErr   *InvalidChallengeTsError
+		windowErr        *ChallengeTsOutsideWindowError
+	)
+	switch {
+	case xerrors.As(err, &scoreErr),
+		xerrors.As(err, &weightedScoreErr),
+		xerrors.As(err, &scoreDropErr),
+		xerrors.As(err, &challengeTsErr),
+		xerrors.As(err, &windowErr):
+		return StepUp
+	default:
+		return Block
+	}
+}
+
+// Evaluate is like EvaluateWith, using DefaultEscalation.
+func (r *Response) Evaluate(criteria ...Criterion) Recommendation {
+	return r.EvaluateWith(DefaultEscalation, criteria...)
+}
+
+// EvaluateWith verifies the response using the provided criteria, and
+// recommends a course of action based on which of them failed, for adaptive
+// authentication flows. If the default check of the Success and ErrorCodes
+// fields fails, Block is recommended. Otherwise, every criterion is applied,
+// and the most severe Recommendation returned by the escalation for any
+// failing criterion is recommended, or Pass if none failed.
+func (r *Response) EvaluateWith(escalation Escalation, criteria ...Criterion) Recommendation {
+	if !r.IsSuccess() {
+		return Block
+	}
+	recommendation := Pass
+	for _, result := range r.Inspect(criteria...) {
+		if result.Err == nil {
+			continue
+		}
+		if rec := escalation(result.Err); rec > recommendation {
+			recommendation = rec
+		}
+	}
+	return recommendation
+}
@@ -0,0 +1,194 @@
+package recaptcha
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response Response
+		signals  []Signal
+		expected Decision
+	}{
+		{
+			name:     "NoSignals/Pass",
+			response: Response{Score: .9},
+			expected: Decision{
+				Score:          .9,
+				Recommendation: Pass,
+			},
+		},
+		{
+			name:     "NoSignals/StepUp",
+			response: Response{Score: .5},
+			expected: Decision{
+				Score:          .5,
+				Recommendation: StepUp,
+			},
+		},
+		{
+			name:     "NoSignals/Block",
+			response: Response{Score: .1},
+			expected: Decision{
+				Score:          .1,
+				Recommendation: Block,
+			},
+		},
+		{
+			name:     "Signals/Weighted",
+			response: Response{Score: .9},
+			signals: []Signal{
+				{Name: "ip-reputation", Score: .1, Weight: 2},
+				{Name: "account-age", Score: .7, Weight: 1},
+			},
+			// (.9 + .1*2 + .7*1) / (1 + 2 + 1)
+			expected: Decision{
+				Score:          .45,
+				Recommendation: StepUp,
+			},
+		},
+		{
+			name:     "Signals/IgnoreNonPositiveWeight",
+			response: Response{Score: .9},
+			signals: []Signal{
+				{Name: "disabled", Score: 0, Weight: 0},
+				{Name: "negative", Score: 0, Weight: -1},
+				{Name: "device", Score: .5, Weight: 1},
+			},
+			// (.9 + .5) / (1 + 1)
+			expected: Decision{
+				Score:          .7,
+				Recommendation: Pass,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := Decide(testCase.response, testCase.signals...)
+			if math.Abs(testCase.expected.Score-actual.Score) > 1e-9 {
+				t.Errorf("Expected score %v, got %v", testCase.expected.Score, actual.Score)
+			} else if testCase.expected.Recommendation != actual.Recommendation {
+				t.Errorf("Expected recommendation %s, got %s", testCase.expected.Recommendation, actual.Recommendation)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		response Response
+		expected Recommendation
+	}{
+		{
+			name: "Pass",
+			response: Response{
+				Success:  true,
+				Score:    .9,
+				Hostname: "niche.com",
+			},
+			expected: Pass,
+		},
+		{
+			name: "StepUp/LowScore",
+			response: Response{
+				Success:  true,
+				Score:    .3,
+				Hostname: "niche.com",
+			},
+			expected: StepUp,
+		},
+		{
+			name: "Block/WrongHostname",
+			response: Response{
+				Success:  true,
+				Score:    .9,
+				Hostname: "example.com",
+			},
+			expected: Block,
+		},
+		{
+			name: "Block/MostSevere",
+			response: Response{
+				Success:  true,
+				Score:    .3,
+				Hostname: "example.com",
+			},
+			expected: Block,
+		},
+		{
+			name: "Block/Unsuccessful",
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"invalid-input-response"},
+			},
+			expected: Block,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.Evaluate(Score(.5), Hostname("niche.com"))
+			if actual != testCase.expected {
+				t.Errorf("Expected %s, got %s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEvaluateWith(t *testing.T) {
+	// Treat a wrong hostname as a soft failure, and everything else as a hard
+	// failure.
+	escalation := func(err error) Recommendation {
+		if _, ok := err.(*InvalidHostnameError); ok {
+			return StepUp
+		}
+		return Block
+	}
+
+	testCases := []struct {
+		name     string
+		response Response
+		expected Recommendation
+	}{
+		{
+			name: "Pass",
+			response: Response{
+				Success:  true,
+				Score:    .9,
+				Hostname: "niche.com",
+			},
+			expected: Pass,
+		},
+		{
+			name: "StepUp",
+			response: Response{
+				Success:  true,
+				Score:    .9,
+				Hostname: "example.com",
+			},
+			expected: StepUp,
+		},
+		{
+			name: "Block",
+			response: Response{
+				Success:  true,
+				Score:    .3,
+				Hostname: "niche.com",
+			},
+			expected: Block,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.EvaluateWith(escalation, Score(.5), Hostname("niche.com"))
+			if actual != testCase.expected {
+				t.Errorf("Expected %s, got %s", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -2,10 +2,57 @@ package recaptcha
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// ScorePrecision is the number of decimal places to which scores, thresholds,
+// and weights are rounded in the messages of the score-related errors (e.g.
+// InvalidScoreError). reCAPTCHA scores are currently reported in steps of 0.1,
+// so this loses no information. The full-precision values remain available via
+// the errors' fields, for callers which need to format them differently.
+const ScorePrecision = 2
+
+// formatScore formats the score to ScorePrecision decimal places.
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', ScorePrecision, 64)
+}
+
+// MissingFieldError is returned from Fetch if a field that was marked as
+// required via the SetRequiredFields option is missing from a successful
+// response. It is also returned from Verify if the ExtraEquals criterion is
+// provided and the extra field is missing.
+type MissingFieldError struct {
+	Field string
+}
+
+func (e *MissingFieldError) Error() string {
+	return fmt.Sprintf("response missing required field: %s", e.Field)
+}
+
+// DecodeError is returned from Fetch if the SetLenientDecoding option was
+// provided and some of the response's fields could not be decoded. Errors maps
+// the JSON name of each malformed field to the error encountered decoding it.
+type DecodeError struct {
+	Errors map[string]error
+}
+
+func (e *DecodeError) Error() string {
+	fields := make([]string, 0, len(e.Errors))
+	for field := range e.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, e.Errors[field]))
+	}
+	return fmt.Sprintf("error decoding fields: %s", strings.Join(messages, "; "))
+}
+
 // VerificationError is returned from Verify when the response's "success"
 // field is false or the "error-codes" field is non-empty. This is the only
 // error the can be returned from Verify if no additional verification criteria
@@ -21,6 +68,19 @@ func (e *VerificationError) Error() string {
 	return "invalid reCAPTCHA (success: false)"
 }
 
+// IsBadRequest reports whether the error codes include "bad-request", which
+// indicates that the request to the verification endpoint was invalid or
+// malformed. In practice, this often means that a malformed userIP (sent as the
+// "remoteip" parameter) or secret was provided to the Client.
+func (e *VerificationError) IsBadRequest() bool {
+	for _, code := range e.ErrorCodes {
+		if code == "bad-request" {
+			return true
+		}
+	}
+	return false
+}
+
 // InvalidHostnameError is returned from Verify if the Hostname criterion is
 // provided and the response's "hostname" field does not correspond to the
 // expected hostname.
@@ -32,6 +92,58 @@ func (e *InvalidHostnameError) Error() string {
 	return fmt.Sprintf("invalid reCAPTCHA: invalid hostname: %s", e.Hostname)
 }
 
+// IPHostnameError is returned from Verify if the HostnameNotIP criterion is
+// provided and the response's "hostname" field is an IP address rather than a
+// domain name.
+type IPHostnameError struct {
+	Hostname string
+}
+
+func (e *IPHostnameError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: hostname is an IP address: %s", e.Hostname)
+}
+
+// HostnameResolutionError is returned from Verify if the HostnameInCIDR
+// criterion is provided and the response's "hostname" field could not be
+// resolved.
+type HostnameResolutionError struct {
+	Hostname string
+	Err      error
+}
+
+func (e *HostnameResolutionError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: error resolving hostname %s: %s", e.Hostname, e.Err)
+}
+
+// Unwrap returns the underlying resolution error.
+func (e *HostnameResolutionError) Unwrap() error {
+	return e.Err
+}
+
+// SecretConfigError is returned from Verify if the NoSecretErrors criterion is
+// provided and the response's "error-codes" field indicates that the secret is
+// missing or invalid. This indicates a misconfigured deployment, rather than an
+// invalid token.
+type SecretConfigError struct {
+	ErrorCodes []string
+}
+
+func (e *SecretConfigError) Error() string {
+	return fmt.Sprintf("reCAPTCHA secret misconfigured: %s", strings.Join(e.ErrorCodes, ","))
+}
+
+// MissingHostnameError is returned from Verify if a criterion which derives
+// the expected hostname from an incoming request or callback (e.g.
+// HostnameMatchesSNI, HostnameMatchesOrigin, or HostnameExpected) is provided,
+// but no expected hostname is available.
+type MissingHostnameError struct {
+	Source string
+}
+
+func (e *MissingHostnameError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: missing expected hostname: no %s", e.Source)
+}
+
 // InvalidActionError is returned from Verify if the Action criterion is
 // provided and the response's "action" field does not correspond to the
 // expected action.
@@ -43,14 +155,98 @@ func (e *InvalidActionError) Error() string {
 	return fmt.Sprintf("invalid reCAPTCHA: invalid action: %s", e.Action)
 }
 
+// MissingActionCookieError is returned from Verify if the ActionFromCookie
+// criterion is provided and the incoming request has no cookie with the
+// expected name.
+type MissingActionCookieError struct {
+	Name string
+}
+
+func (e *MissingActionCookieError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: missing action cookie: %s", e.Name)
+}
+
+// InvalidActionCookieError is returned from Verify if the ActionFromCookie
+// criterion is provided and the value of the incoming request's cookie is
+// malformed or has an invalid signature, indicating that it was tampered with
+// or signed using a different key.
+type InvalidActionCookieError struct {
+	Name string
+}
+
+func (e *InvalidActionCookieError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: invalid action cookie: %s", e.Name)
+}
+
+// ExpiredActionCookieError is returned from Verify if the ActionFromCookie
+// criterion is provided and the incoming request's cookie has a valid
+// signature, but has expired.
+type ExpiredActionCookieError struct {
+	Name    string
+	Expires time.Time
+}
+
+func (e *ExpiredActionCookieError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: action cookie %s expired at %s", e.Name, e.Expires.Format(time.RFC3339))
+}
+
 // InvalidScoreError is returned from Verify if the Score criterion is provided
 // and the response's "score" field is below the minimum threshold.
 type InvalidScoreError struct {
-	Score float64
+	Score     float64
+	Threshold float64
 }
 
 func (e *InvalidScoreError) Error() string {
-	return fmt.Sprintf("invalid reCAPTCHA: invalid score: %f", e.Score)
+	return fmt.Sprintf("invalid reCAPTCHA: invalid score: %s (threshold: %s)", formatScore(e.Score), formatScore(e.Threshold))
+}
+
+// ZeroScoreError is returned from Verify if the RejectZeroScore criterion is
+// provided and the response's "score" field is exactly zero, indicating a
+// near-certain bot.
+type ZeroScoreError struct{}
+
+func (e *ZeroScoreError) Error() string {
+	return "invalid reCAPTCHA: score is zero"
+}
+
+// InvalidWeightedScoreError is returned from Verify if the WeightedScore
+// criterion is provided and the response's score, scaled by the weight of its
+// action, is below the minimum threshold.
+type InvalidWeightedScoreError struct {
+	Score          float64
+	Weight         float64
+	EffectiveScore float64
+	Threshold      float64
+}
+
+func (e *InvalidWeightedScoreError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: invalid effective score: %s (score: %s, weight: %s, threshold: %s)", formatScore(e.EffectiveScore), formatScore(e.Score), formatScore(e.Weight), formatScore(e.Threshold))
+}
+
+// ScoreDropError is returned from Verify if the ScoreVsBaseline criterion is
+// provided and the response's "score" field is more than the maximum drop
+// below the baseline.
+type ScoreDropError struct {
+	Score    float64
+	Baseline float64
+	MaxDrop  float64
+}
+
+func (e *ScoreDropError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: score %s dropped more than %s below baseline %s", formatScore(e.Score), formatScore(e.MaxDrop), formatScore(e.Baseline))
+}
+
+// InvalidScorePrecisionError is returned from Verify if the ScoreQuantized
+// criterion is provided and the response's "score" field is not a multiple of
+// the expected step.
+type InvalidScorePrecisionError struct {
+	Score float64
+	Step  float64
+}
+
+func (e *InvalidScorePrecisionError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: score %v is not a multiple of %v", e.Score, e.Step)
 }
 
 // InvalidChallengeTsError is returned from Verify if the ChallengeTs criterion
@@ -64,3 +260,198 @@ type InvalidChallengeTsError struct {
 func (e *InvalidChallengeTsError) Error() string {
 	return fmt.Sprintf("invalid reCAPTCHA: invalid challenge timestamp: %s (%s old)", e.ChallengeTs, e.Diff)
 }
+
+// HTTPStatusError is returned from Fetch if the reCAPTCHA verification
+// endpoint responds with a non-2xx status code.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status code: %d", e.StatusCode)
+}
+
+// EndpointNotFoundError is returned from Fetch if the reCAPTCHA verification
+// endpoint responds with a 404 status code. This almost always indicates that
+// the URL provided via the SetURL or SetFallbackURL option (whichever URL is
+// reported) is misconfigured.
+type EndpointNotFoundError struct {
+	URL string
+}
+
+func (e *EndpointNotFoundError) Error() string {
+	return fmt.Sprintf("verification endpoint not found (check the configured URL): %s", e.URL)
+}
+
+// UnexpectedResponseError is returned from Fetch if the
+// SetRequireJSONContentType option was provided and the verification
+// endpoint's response does not have a JSON Content-Type. ContentType is empty
+// if the header was missing.
+type UnexpectedResponseError struct {
+	ContentType string
+}
+
+func (e *UnexpectedResponseError) Error() string {
+	if e.ContentType == "" {
+		return "unexpected response: missing Content-Type"
+	}
+	return fmt.Sprintf("unexpected response Content-Type: %s", e.ContentType)
+}
+
+// ResponseTooLargeError is returned from Fetch if the SetLimits option was
+// provided and the verification endpoint's response body exceeds the maximum
+// size.
+type ResponseTooLargeError struct {
+	MaxBytes int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds maximum size of %d bytes", e.MaxBytes)
+}
+
+// NonUTCChallengeTsError is returned from Verify if the ChallengeTsUTC
+// criterion is provided and the response's "challenge_ts" field is not in UTC.
+type NonUTCChallengeTsError struct {
+	ChallengeTs time.Time
+}
+
+func (e *NonUTCChallengeTsError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: challenge timestamp not in UTC: %s", e.ChallengeTs)
+}
+
+// ChallengeTsBeforeStartError is returned from Verify if the
+// ChallengeTsAfterStart criterion is provided and the response's
+// "challenge_ts" field predates the start of the current process.
+type ChallengeTsBeforeStartError struct {
+	ChallengeTs time.Time
+	Start       time.Time
+}
+
+func (e *ChallengeTsBeforeStartError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: challenge timestamp %s predates process start %s", e.ChallengeTs, e.Start)
+}
+
+// ChallengeTsOutsideWindowError is returned from Verify if the
+// ChallengeTsInWindow criterion is provided and the time of day of the
+// response's "challenge_ts" field falls outside the window. ChallengeTs is
+// given in the window's location, and Start and End are offsets from
+// midnight.
+type ChallengeTsOutsideWindowError struct {
+	ChallengeTs time.Time
+	Start       time.Duration
+	End         time.Duration
+}
+
+func (e *ChallengeTsOutsideWindowError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: challenge timestamp %s outside daily window %s-%s", e.ChallengeTs, e.Start, e.End)
+}
+
+// ClockSkewError is returned from Verify if the ClockSkewWithin criterion is
+// provided and the local clock differs from the time reported by the
+// response's "server_time" extra field by more than the tolerance.
+type ClockSkewError struct {
+	ServerTime time.Time
+	Skew       time.Duration
+	Tolerance  time.Duration
+}
+
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: clock skew of %s from server time %s exceeds %s", e.Skew, e.ServerTime, e.Tolerance)
+}
+
+// SchemaMismatchError is returned from Verify if the ExpectSchema criterion is
+// provided and the response's SchemaVersion is not the expected version. The
+// fields which were present in the response are included to help diagnose the
+// drift.
+type SchemaMismatchError struct {
+	Expected      int
+	Actual        int
+	PresentFields []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: expected schema version %d, got %d (fields: %s)", e.Expected, e.Actual, strings.Join(e.PresentFields, ","))
+}
+
+// MismatchError is returned from Verify if the Matches criterion is provided
+// and one of the response's fields does not match the expected response, or
+// if the ExtraEquals criterion is provided and the extra field does not have
+// the expected value.
+type MismatchError struct {
+	Field    string
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: %s mismatch: expected %v, got %v", e.Field, e.Expected, e.Actual)
+}
+
+// InconsistentResponseError is returned from ConsistentAcross if a response's
+// field does not match the corresponding field of the first response.
+type InconsistentResponseError struct {
+	Index    int
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (e *InconsistentResponseError) Error() string {
+	return fmt.Sprintf("inconsistent reCAPTCHA responses: response %d has %s %q (expected %q)", e.Index, e.Field, e.Actual, e.Expected)
+}
+
+// QuorumError is returned from Quorum if fewer than the required number of
+// responses pass verification. Errs contains the error for each response, in
+// the same order as the responses, as returned by VerifyMany.
+type QuorumError struct {
+	Required int
+	Passed   int
+	Errs     []error
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: %d of %d responses passed verification (required: %d)", e.Passed, len(e.Errs), e.Required)
+}
+
+// RemotePolicyDeniedError is returned from Verify if the RemotePolicy
+// criterion is provided and the remote policy service denies the response.
+// Reason is the explanation given by the service, if any.
+type RemotePolicyDeniedError struct {
+	Reason string
+}
+
+func (e *RemotePolicyDeniedError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("invalid reCAPTCHA: denied by remote policy: %s", e.Reason)
+	}
+	return "invalid reCAPTCHA: denied by remote policy"
+}
+
+// RemotePolicyError is returned from Verify if the RemotePolicy criterion is
+// provided without failing open, and the remote policy service could not be
+// reached or did not return a valid verdict.
+type RemotePolicyError struct {
+	URL string
+	Err error
+}
+
+func (e *RemotePolicyError) Error() string {
+	return fmt.Sprintf("invalid reCAPTCHA: error consulting remote policy %s: %s", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RemotePolicyError) Unwrap() error {
+	return e.Err
+}
+
+// ActionBindingError is returned from Fetch if the SetActionBinding option was
+// provided and the token was previously presented with different expected
+// actions. If several actions were expected, they are joined with commas.
+type ActionBindingError struct {
+	BoundAction string
+	Action      string
+}
+
+func (e *ActionBindingError) Error() string {
+	return fmt.Sprintf("token previously presented for action %q, got %q", e.BoundAction, e.Action)
+}
@@ -0,0 +1,92 @@
+package recaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Possible values of an Event's Outcome.
+const (
+	// OutcomeSuccess indicates that the response passed the default
+	// verification check (i.e. "success" was true and "error-codes" was
+	// empty).
+	OutcomeSuccess = "success"
+	// OutcomeFailure indicates that the response failed the default
+	// verification check.
+	OutcomeFailure = "failure"
+	// OutcomeError indicates that Fetch returned an error.
+	OutcomeError = "error"
+)
+
+// Event is the record of a call to Fetch written by the SetEventWriter option,
+// as a line of JSON. The token is identified only by its hash, and the secret
+// is never included. RequestID is the ID stored in the context passed to Fetch
+// by WithRequestID, if any.
+type Event struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	TokenHash  string    `json:"token_hash"`
+	Outcome    string    `json:"outcome"`
+	Score      float64   `json:"score"`
+	Action     string    `json:"action,omitempty"`
+	Hostname   string    `json:"hostname,omitempty"`
+	ErrorCodes []string  `json:"error_codes,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// SetEventWriter is an option for creating a Client which writes an Event for
+// each call to Fetch to the provided io.Writer (e.g. an append-only file or
+// os.Stdout) as a line of JSON, as a lightweight audit trail. The token is
+// recorded as the hex-encoded SHA-256 hash of its value. Writes are serialized,
+// so that lines are never interleaved, and write errors are ignored. The
+// events are written synchronously, before Fetch returns, so slow writers
+// should be buffered.
+func SetEventWriter(w io.Writer) Option {
+	return func(c *client) {
+		c.eventWriter = &eventWriter{
+			w: w,
+		}
+	}
+}
+
+// eventWriter writes Events to an io.Writer, one at a time.
+type eventWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write writes the Event for a call to Fetch with the provided context, token,
+// and result.
+func (e *eventWriter) write(ctx context.Context, token string, r Response, err error) {
+	event := Event{
+		Time:       now().UTC(),
+		RequestID:  RequestID(ctx),
+		TokenHash:  hashToken(token),
+		Score:      r.Score,
+		Action:     r.Action,
+		Hostname:   r.Hostname,
+		ErrorCodes: r.ErrorCodes,
+	}
+	switch {
+	case err != nil:
+		event.Outcome = OutcomeError
+		event.Error = err.Error()
+	case r.IsSuccess():
+		event.Outcome = OutcomeSuccess
+	default:
+		event.Outcome = OutcomeFailure
+	}
+
+	line, marshalErr := json.Marshal(event)
+	if marshalErr != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(line)
+}
@@ -0,0 +1,148 @@
+package recaptcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetEventWriter(t *testing.T) {
+	current := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+	defer func(original func() time.Time) {
+		now = original
+	}(now)
+	now = func() time.Time {
+		return current
+	}
+
+	// sha256("token")
+	const tokenHash = "3c469e9d6c5875d37a43f353d4f88e61fcf812c66eee3457465a40b0da4153e0"
+
+	respond := func(body string) func(req *http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+			}, nil
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		doStub   func(req *http.Request) (*http.Response, error)
+		expected Event
+	}{
+		{
+			name:   "Success",
+			doStub: respond(`{"success": true, "score": 0.9, "action": "login", "hostname": "niche.com"}`),
+			expected: Event{
+				Time:      current,
+				TokenHash: tokenHash,
+				Outcome:   OutcomeSuccess,
+				Score:     .9,
+				Action:    "login",
+				Hostname:  "niche.com",
+			},
+		},
+		{
+			name:   "Failure",
+			doStub: respond(`{"success": false, "error-codes": ["timeout-or-duplicate"]}`),
+			expected: Event{
+				Time:       current,
+				TokenHash:  tokenHash,
+				Outcome:    OutcomeFailure,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+		},
+		{
+			name: "Error",
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("AAHHH")
+			},
+			expected: Event{
+				Time:      current,
+				TokenHash: tokenHash,
+				Outcome:   OutcomeError,
+				Error:     "error making POST request: AAHHH",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			client := NewClient("my_secret",
+				SetHTTPClient(&httpClientMock{
+					doStub: testCase.doStub,
+				}),
+				SetEventWriter(&buf),
+			)
+			client.Fetch(context.Background(), "token", "192.168.0.1")
+			client.Fetch(context.Background(), "token", "192.168.0.1")
+
+			output := buf.String()
+			if strings.Contains(output, "my_secret") {
+				t.Errorf("Secret written to events: %s", output)
+			}
+			if strings.Contains(output, `"token"`) {
+				t.Errorf("Raw token written to events: %s", output)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("Expected 2 lines, got %d: %q", len(lines), output)
+			}
+			for _, line := range lines {
+				var actual Event
+				if err := json.Unmarshal([]byte(line), &actual); err != nil {
+					t.Fatalf("Unexpected error decoding %q: %s", line, err)
+				}
+				if !reflect.DeepEqual(testCase.expected, actual) {
+					t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestSetEventWriterRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	client := NewClient("secret",
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+				}, nil
+			},
+		}),
+		SetEventWriter(&buf),
+	)
+	client.Fetch(WithRequestID(context.Background(), "req-123"), "token", "")
+	client.Fetch(context.Background(), "token", "")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	expected := []string{"req-123", ""}
+	for i, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Unexpected error decoding %q: %s", line, err)
+		}
+		if event.RequestID != expected[i] {
+			t.Errorf("Expected request ID %q, got %q", expected[i], event.RequestID)
+		}
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("Expected request ID to be omitted, got %s", lines[1])
+	}
+}
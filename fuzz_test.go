@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package recaptcha
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func FuzzParseResponse(f *testing.F) {
+	seeds := []string{
+		`{"success": true, "score": 0.5, "action": "login", "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com", "error-codes": []}`,
+		`{"success": false, "error-codes": ["invalid-input-response", "timeout-or-duplicate"]}`,
+		`{"success": true, "score": 1e400}`,
+		`{"success": true, "score": -0.1}`,
+		`{"score": "invalid"}`,
+		`{"challenge_ts": "yesterday"}`,
+		`{"error-codes": [1, 2, 3]}`,
+		`{"success": true, "extra": ` + strings.Repeat(`[`, 1000) + strings.Repeat(`]`, 1000) + `}`,
+		`null`,
+		`[]`,
+		`""`,
+		`{`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		response, err := parseResponse(body)
+		if err != nil {
+			return
+		}
+		if !json.Valid(body) {
+			t.Errorf("Expected error for invalid JSON %q, got response: %#v", body, response)
+		}
+		if _, err := json.Marshal(response); err != nil {
+			t.Errorf("Error re-marshalling response parsed from %q: %s", body, err)
+		}
+	})
+}
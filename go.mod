@@ -2,4 +2,7 @@ module github.com/nicheinc/recaptcha
 
 go 1.12
 
-require golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7
+require (
+	golang.org/x/net v0.0.0-20190827160401-ba9fcec4b297
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7
+)
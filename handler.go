@@ -0,0 +1,88 @@
+package recaptcha
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// VerifyRequest is the JSON request body accepted by VerifyHTTPHandler.
+type VerifyRequest struct {
+	Token  string `json:"token"`
+	UserIP string `json:"userIP"`
+}
+
+// VerifyResult is the JSON response body returned by VerifyHTTPHandler.
+type VerifyResult struct {
+	Valid    bool    `json:"valid"`
+	Score    float64 `json:"score"`
+	Action   string  `json:"action"`
+	Hostname string  `json:"hostname"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// verifyRequestMaxBytes is the maximum size of a request body accepted by
+// VerifyHTTPHandler. Tokens are a few kilobytes at most, so anything larger is
+// rejected without being read in full.
+const verifyRequestMaxBytes = 16 << 10
+
+// VerifyHTTPHandler returns an http.Handler which verifies reCAPTCHA tokens on
+// behalf of other services. It accepts POST requests with a JSON VerifyRequest
+// body, fetches the token verification response using the provided Client,
+// verifies it using the provided criteria, and responds with a JSON
+// VerifyResult. Invalid tokens result in a 200 response with "valid" set to
+// false. Malformed requests, and request bodies larger than 16 KiB, result in
+// a 400 response, and errors contacting the reCAPTCHA verification endpoint
+// result in a 502 response.
+func VerifyHTTPHandler(client Client, criteria ...Criterion) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeResult(w, http.StatusMethodNotAllowed, VerifyResult{
+				Error: "method not allowed",
+			})
+			return
+		}
+
+		var request VerifyRequest
+		body := http.MaxBytesReader(w, r.Body, verifyRequestMaxBytes)
+		if err := json.NewDecoder(body).Decode(&request); err != nil {
+			writeResult(w, http.StatusBadRequest, VerifyResult{
+				Error: "error decoding request body: " + err.Error(),
+			})
+			return
+		}
+		if request.Token == "" {
+			writeResult(w, http.StatusBadRequest, VerifyResult{
+				Error: "request missing token",
+			})
+			return
+		}
+
+		response, err := client.Fetch(r.Context(), request.Token, request.UserIP)
+		if err != nil {
+			writeResult(w, http.StatusBadGateway, VerifyResult{
+				Error: "error fetching verification response: " + err.Error(),
+			})
+			return
+		}
+
+		result := VerifyResult{
+			Valid:    true,
+			Score:    response.Score,
+			Action:   response.Action,
+			Hostname: response.Hostname,
+		}
+		if err := response.Verify(criteria...); err != nil {
+			result.Valid = false
+			result.Error = err.Error()
+		}
+		writeResult(w, http.StatusOK, result)
+	})
+}
+
+// writeResult writes a JSON VerifyResult with the provided status code.
+func writeResult(w http.ResponseWriter, status int, result VerifyResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,155 @@
+package recaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyHTTPHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		body     string
+		client   *Mock
+		criteria []Criterion
+		status   int
+		expected VerifyResult
+	}{
+		{
+			name:   "MethodNotAllowed",
+			method: http.MethodGet,
+			client: &Mock{},
+			status: http.StatusMethodNotAllowed,
+			expected: VerifyResult{
+				Error: "method not allowed",
+			},
+		},
+		{
+			name:   "InvalidBody",
+			method: http.MethodPost,
+			body:   `{`,
+			client: &Mock{},
+			status: http.StatusBadRequest,
+			expected: VerifyResult{
+				Error: "error decoding request body: unexpected EOF",
+			},
+		},
+		{
+			name:   "BodyTooLarge",
+			method: http.MethodPost,
+			body:   `{"token": "` + strings.Repeat("a", verifyRequestMaxBytes) + `"}`,
+			client: &Mock{},
+			status: http.StatusBadRequest,
+			expected: VerifyResult{
+				Error: "error decoding request body: http: request body too large",
+			},
+		},
+		{
+			name:   "MissingToken",
+			method: http.MethodPost,
+			body:   `{"userIP": "192.169.0.1"}`,
+			client: &Mock{},
+			status: http.StatusBadRequest,
+			expected: VerifyResult{
+				Error: "request missing token",
+			},
+		},
+		{
+			name:   "FetchError",
+			method: http.MethodPost,
+			body:   `{"token": "token", "userIP": "192.169.0.1"}`,
+			client: &Mock{
+				FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+					return Response{}, errors.New("AAHHH")
+				},
+			},
+			status: http.StatusBadGateway,
+			expected: VerifyResult{
+				Error: "error fetching verification response: AAHHH",
+			},
+		},
+		{
+			name:   "Invalid",
+			method: http.MethodPost,
+			body:   `{"token": "token", "userIP": "192.169.0.1"}`,
+			client: &Mock{
+				FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+					return Response{
+						Success:  true,
+						Score:    .3,
+						Action:   "login",
+						Hostname: "niche.com",
+					}, nil
+				},
+			},
+			criteria: []Criterion{
+				Score(.5),
+			},
+			status: http.StatusOK,
+			expected: VerifyResult{
+				Valid:    false,
+				Score:    .3,
+				Action:   "login",
+				Hostname: "niche.com",
+				Error:    (&InvalidScoreError{Score: .3, Threshold: .5}).Error(),
+			},
+		},
+		{
+			name:   "Valid",
+			method: http.MethodPost,
+			body:   `{"token": "token", "userIP": "192.169.0.1"}`,
+			client: &Mock{
+				FetchStub: func(ctx context.Context, token string, userIP string) (Response, error) {
+					if token != "token" || userIP != "192.169.0.1" {
+						return Response{}, errors.New("unexpected parameters")
+					}
+					return Response{
+						Success:  true,
+						Score:    .7,
+						Action:   "login",
+						Hostname: "niche.com",
+					}, nil
+				},
+			},
+			criteria: []Criterion{
+				Score(.5),
+			},
+			status: http.StatusOK,
+			expected: VerifyResult{
+				Valid:    true,
+				Score:    .7,
+				Action:   "login",
+				Hostname: "niche.com",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			handler := VerifyHTTPHandler(testCase.client, testCase.criteria...)
+			req := httptest.NewRequest(testCase.method, "/verify", strings.NewReader(testCase.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != testCase.status {
+				t.Errorf("Expected status %d, got %d", testCase.status, rec.Code)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", contentType)
+			}
+			var actual VerifyResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("Error unmarshalling response body: %s", err)
+			}
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package recaptcha
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// SecureHTTPClient returns an *http.Client suitable for passing to the
+// SetHTTPClient option, which requires TLS 1.2 or higher and has sane timeouts
+// (10 seconds for the overall request).
+func SecureHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: newTransport(dialer.DialContext),
+	}
+}
+
+// HTTPClientForNetwork returns an *http.Client suitable for passing to the
+// SetHTTPClient option, which is configured like SecureHTTPClient, but always
+// dials connections using the provided network ("tcp4" or "tcp6", or "tcp" for
+// either). This makes it possible to work around broken IPv4 or IPv6 routes.
+// Returns an error if the network is not one of those.
+func HTTPClientForNetwork(network string) (*http.Client, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, xerrors.Errorf(`invalid network %q: must be "tcp", "tcp4", or "tcp6"`, network)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: newTransport(func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}),
+	}, nil
+}
+
+// newTransport creates an *http.Transport which requires TLS 1.2 or higher and
+// dials connections using the provided function.
+func newTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport {
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dial,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+}
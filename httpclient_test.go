@@ -0,0 +1,108 @@
+package recaptcha
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSecureHTTPClient(t *testing.T) {
+	client := SecureHTTPClient()
+	if client.Timeout != 10*time.Second {
+		t.Errorf("Expected timeout %s, got %s", 10*time.Second, client.Timeout)
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", client.Transport)
+	}
+	if transport.TLSClientConfig == nil {
+		t.Fatalf("Expected TLS config, got nil")
+	}
+	if transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected min TLS version %x, got %x", tls.VersionTLS12, transport.TLSClientConfig.MinVersion)
+	}
+	if transport.TLSHandshakeTimeout == 0 {
+		t.Errorf("Expected TLS handshake timeout to be set")
+	}
+}
+
+func TestHTTPClientForNetwork(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	addr := listener.Addr().String()
+
+	testCases := []struct {
+		name    string
+		network string
+		err     bool
+	}{
+		{
+			name:    "TCP",
+			network: "tcp",
+			err:     false,
+		},
+		{
+			name:    "TCP4",
+			network: "tcp4",
+			err:     false,
+		},
+		{
+			name:    "TCP6",
+			network: "tcp6",
+			err:     true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, err := HTTPClientForNetwork(testCase.network)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Expected *http.Transport, got %T", client.Transport)
+			}
+			if transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+				t.Errorf("Expected min TLS version %x, got %x", tls.VersionTLS12, transport.TLSClientConfig.MinVersion)
+			}
+
+			// The transport dials with the configured network, regardless of
+			// the network it is asked to use, so an IPv4 address can only be
+			// dialed via "tcp4".
+			conn, err := transport.DialContext(context.Background(), "tcp", addr)
+			if conn != nil {
+				conn.Close()
+			}
+			if (err != nil) != testCase.err {
+				t.Errorf("Expected error: %t, got: %v", testCase.err, err)
+			}
+		})
+	}
+}
+
+func TestHTTPClientForNetworkInvalid(t *testing.T) {
+	for _, network := range []string{"", "udp", "TCP4", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			if client, err := HTTPClientForNetwork(network); err == nil {
+				t.Errorf("Expected error, got client %#v", client)
+			}
+		})
+	}
+}
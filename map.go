@@ -0,0 +1,196 @@
+package recaptcha
+
+import (
+	"encoding/json"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ToMap returns the response as a map from JSON field names to values, as a
+// transport-agnostic serialization (e.g. for conversion to a
+// google.protobuf.Struct, or for encoding by a custom gRPC message mapping).
+// It can be converted back to a Response by ResponseFromMap.
+//
+// A standard field is included if it was present in the decoded response or
+// has a non-zero value, and has one of the following types:
+//
+//	"success"       bool
+//	"score"         float64
+//	"action"        string
+//	"challenge_ts"  time.Time
+//	"hostname"      string
+//	"error-codes"   []string
+//
+// The challenge timestamp is kept as a time.Time, rather than formatted as a
+// string, so that transports with a native timestamp type (e.g.
+// google.protobuf.Timestamp) can encode it as one. Each of the fields in Extra
+// is included as a json.RawMessage. The map does not share any memory with the
+// response.
+func (r *Response) ToMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(responseFields)+len(r.Extra))
+	if r.present&successField != 0 || r.Success {
+		m["success"] = r.Success
+	}
+	if r.present&scoreField != 0 || r.Score != 0 {
+		m["score"] = r.Score
+	}
+	if r.present&actionField != 0 || r.Action != "" {
+		m["action"] = r.Action
+	}
+	if r.present&challengeTsField != 0 || !r.ChallengeTs.IsZero() {
+		m["challenge_ts"] = r.ChallengeTs
+	}
+	if r.present&hostnameField != 0 || r.Hostname != "" {
+		m["hostname"] = r.Hostname
+	}
+	if r.present&errorCodesField != 0 || len(r.ErrorCodes) > 0 {
+		errorCodes := make([]string, len(r.ErrorCodes))
+		copy(errorCodes, r.ErrorCodes)
+		m["error-codes"] = errorCodes
+	}
+	for name, raw := range r.Extra {
+		value := make(json.RawMessage, len(raw))
+		copy(value, raw)
+		m[name] = value
+	}
+	return m
+}
+
+// ResponseFromMap converts a map produced by ToMap back into a Response,
+// including the record of which fields were present (see HasScore and
+// PresentFields). Like UnmarshalJSON, standard field names are matched
+// case-insensitively, nil values are not considered present, and any other
+// fields are stored in Extra.
+//
+// In addition to the types produced by ToMap, the types produced by generic
+// decoders (e.g. encoding/json, or the AsMap method of a
+// google.protobuf.Struct) are accepted: "score" may be any numeric type or a
+// json.Number, "challenge_ts" may be an RFC 3339 string, "error-codes" may be
+// a []interface{} of strings, and extra fields which are not json.RawMessage
+// are encoded as JSON. If some of the standard fields have an unexpected
+// type, the remaining fields are still converted, and the resulting Response
+// is returned along with a *DecodeError.
+func ResponseFromMap(m map[string]interface{}) (Response, error) {
+	var (
+		response Response
+		errs     = map[string]error{}
+	)
+	for name, value := range m {
+		field := responseFieldSet(name)
+		if field == 0 {
+			raw, err := toRawMessage(value)
+			if err != nil {
+				errs[name] = err
+				continue
+			}
+			if response.Extra == nil {
+				response.Extra = map[string]json.RawMessage{}
+			}
+			response.Extra[name] = raw
+			continue
+		}
+		if value == nil {
+			continue
+		}
+
+		var err error
+		switch field {
+		case successField:
+			response.Success, err = toBool(value)
+		case scoreField:
+			response.Score, err = toFloat64(value)
+		case actionField:
+			response.Action, err = toString(value)
+		case challengeTsField:
+			response.ChallengeTs, err = toTime(value)
+		case hostnameField:
+			response.Hostname, err = toString(value)
+		case errorCodesField:
+			response.ErrorCodes, err = toStrings(value)
+		}
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		response.present |= field
+	}
+
+	if len(errs) > 0 {
+		return response, &DecodeError{
+			Errors: errs,
+		}
+	}
+	return response, nil
+}
+
+func toBool(value interface{}) (bool, error) {
+	if v, ok := value.(bool); ok {
+		return v, nil
+	}
+	return false, xerrors.Errorf("expected bool, got %T", value)
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	}
+	return 0, xerrors.Errorf("expected number, got %T", value)
+}
+
+func toString(value interface{}) (string, error) {
+	if v, ok := value.(string); ok {
+		return v, nil
+	}
+	return "", xerrors.Errorf("expected string, got %T", value)
+}
+
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	}
+	return time.Time{}, xerrors.Errorf("expected time.Time or RFC 3339 string, got %T", value)
+}
+
+func toStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []string:
+		strs := make([]string, len(v))
+		copy(strs, v)
+		return strs, nil
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, elem := range v {
+			str, ok := elem.(string)
+			if !ok {
+				return nil, xerrors.Errorf("expected string at index %d, got %T", i, elem)
+			}
+			strs[i] = str
+		}
+		return strs, nil
+	}
+	return nil, xerrors.Errorf("expected []string, got %T", value)
+}
+
+func toRawMessage(value interface{}) (json.RawMessage, error) {
+	if v, ok := value.(json.RawMessage); ok {
+		raw := make(json.RawMessage, len(v))
+		copy(raw, v)
+		return raw, nil
+	}
+	return json.Marshal(value)
+}
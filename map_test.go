@@ -0,0 +1,207 @@
+package recaptcha
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestResponseMapRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "V3",
+			body: `{"success": true, "score": 0.9, "action": "login", "challenge_ts": "2019-08-25T16:20:00.123Z", "hostname": "niche.com"}`,
+		},
+		{
+			name: "V2",
+			body: `{"success": true, "challenge_ts": "2019-08-25T16:20:00Z", "hostname": "niche.com"}`,
+		},
+		{
+			name: "ZeroScore",
+			body: `{"success": true, "score": 0.0, "action": "login"}`,
+		},
+		{
+			name: "ErrorCodes",
+			body: `{"success": false, "error-codes": ["invalid-input-response", "timeout-or-duplicate"]}`,
+		},
+		{
+			name: "NullField",
+			body: `{"success": true, "score": null}`,
+		},
+		{
+			name: "Extra",
+			body: `{"success": true, "score": 0.9, "risk": {"level":"low","reasons":[]}, "region": "us"}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			expected, err := parseResponse([]byte(testCase.body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			actual, err := ResponseFromMap(expected.ToMap())
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+			}
+		})
+	}
+}
+
+func TestResponseToMap(t *testing.T) {
+	challengeTs := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		response Response
+		expected map[string]interface{}
+	}{
+		{
+			name:     "Empty",
+			response: Response{},
+			expected: map[string]interface{}{},
+		},
+		{
+			name: "Constructed",
+			response: Response{
+				Success:     true,
+				Score:       .9,
+				ChallengeTs: challengeTs,
+				Extra: map[string]json.RawMessage{
+					"region": json.RawMessage(`"us"`),
+				},
+			},
+			expected: map[string]interface{}{
+				"success":      true,
+				"score":        .9,
+				"challenge_ts": challengeTs,
+				"region":       json.RawMessage(`"us"`),
+			},
+		},
+		{
+			name: "Present",
+			response: Response{
+				present: successField | scoreField | errorCodesField,
+			},
+			expected: map[string]interface{}{
+				"success":     false,
+				"score":       0.0,
+				"error-codes": []string{},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.ToMap()
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResponseFromMap(t *testing.T) {
+	testCases := []struct {
+		name           string
+		m              map[string]interface{}
+		expected       Response
+		expectedErrors []string
+	}{
+		{
+			name: "GenericTypes",
+			m: map[string]interface{}{
+				"success":      true,
+				"score":        json.Number("0.9"),
+				"action":       "login",
+				"challenge_ts": "2019-08-25T16:20:00Z",
+				"hostname":     "niche.com",
+				"error-codes":  []interface{}{"timeout-or-duplicate"},
+				"risk":         map[string]interface{}{"level": "low"},
+			},
+			expected: Response{
+				Success:     true,
+				Score:       .9,
+				Action:      "login",
+				ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+				Hostname:    "niche.com",
+				ErrorCodes:  []string{"timeout-or-duplicate"},
+				Extra: map[string]json.RawMessage{
+					"risk": json.RawMessage(`{"level":"low"}`),
+				},
+				present: v3Fields | errorCodesField,
+			},
+		},
+		{
+			name: "CaseInsensitive",
+			m: map[string]interface{}{
+				"Success": true,
+				"SCORE":   1,
+			},
+			expected: Response{
+				Success: true,
+				Score:   1,
+				present: successField | scoreField,
+			},
+		},
+		{
+			name: "Nil",
+			m: map[string]interface{}{
+				"success": true,
+				"score":   nil,
+			},
+			expected: Response{
+				Success: true,
+				present: successField,
+			},
+		},
+		{
+			name: "WrongTypes",
+			m: map[string]interface{}{
+				"success":      true,
+				"score":        "high",
+				"challenge_ts": "yesterday",
+				"error-codes":  []interface{}{"bad-request", 42},
+				"hostname":     "niche.com",
+			},
+			expected: Response{
+				Success:  true,
+				Hostname: "niche.com",
+				present:  successField | hostnameField,
+			},
+			expectedErrors: []string{"challenge_ts", "error-codes", "score"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ResponseFromMap(testCase.m)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+
+			var actualErrors []string
+			if err != nil {
+				decodeErr, ok := err.(*DecodeError)
+				if !ok {
+					t.Fatalf("Expected *DecodeError, got %#v", err)
+				}
+				for field := range decodeErr.Errors {
+					actualErrors = append(actualErrors, field)
+				}
+				sort.Strings(actualErrors)
+			}
+			if !reflect.DeepEqual(testCase.expectedErrors, actualErrors) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expectedErrors, actualErrors)
+			}
+		})
+	}
+}
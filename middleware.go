@@ -0,0 +1,111 @@
+package recaptcha
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Default names of the headers set by VerifyMiddleware.
+const (
+	DefaultScoreHeader  = "X-Recaptcha-Score"
+	DefaultActionHeader = "X-Recaptcha-Action"
+)
+
+// middleware holds the configuration of VerifyMiddleware.
+type middleware struct {
+	tokenFunc    func(r *http.Request) string
+	userIPFunc   func(r *http.Request) string
+	scoreHeader  string
+	actionHeader string
+}
+
+// MiddlewareOption is an option for creating middleware via VerifyMiddleware.
+type MiddlewareOption func(m *middleware)
+
+// SetTokenFunc is an option for creating middleware via VerifyMiddleware which
+// extracts the token from each request using the provided function. If not
+// provided, the token is read from the "g-recaptcha-response" form value, as
+// submitted by the reCAPTCHA widget.
+func SetTokenFunc(tokenFunc func(r *http.Request) string) MiddlewareOption {
+	return func(m *middleware) {
+		m.tokenFunc = tokenFunc
+	}
+}
+
+// SetUserIPFunc is an option for creating middleware via VerifyMiddleware which
+// extracts the user's IP address from each request using the provided
+// function, e.g. from a header set by a trusted reverse proxy. If not provided,
+// the host portion of the request's RemoteAddr is used, which is the address
+// of the proxy rather than the user if the server sits behind one.
+func SetUserIPFunc(userIPFunc func(r *http.Request) string) MiddlewareOption {
+	return func(m *middleware) {
+		m.userIPFunc = userIPFunc
+	}
+}
+
+// SetHeaderNames is an option for creating middleware via VerifyMiddleware
+// which uses custom names for the score and action headers. Empty names are
+// left unchanged. If not provided, DefaultScoreHeader and DefaultActionHeader
+// are used.
+func SetHeaderNames(score, action string) MiddlewareOption {
+	return func(m *middleware) {
+		if score != "" {
+			m.scoreHeader = score
+		}
+		if action != "" {
+			m.actionHeader = action
+		}
+	}
+}
+
+// VerifyMiddleware returns middleware which verifies the reCAPTCHA token of
+// each request, using the provided Client and criteria, before passing the
+// request on to the wrapped handler, e.g. in an edge authentication layer. On
+// success, the verified score and action are set in the request's score and
+// action headers (X-Recaptcha-Score and X-Recaptcha-Action, unless renamed via
+// SetHeaderNames), so that downstream handlers and services can consume them
+// without verifying the token again. Any values the client sent in those
+// headers are removed first, so they cannot be spoofed. Requests with invalid
+// tokens result in a 403 response, and errors contacting the reCAPTCHA
+// verification endpoint result in a 502 response.
+func VerifyMiddleware(client Client, criteria []Criterion, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := middleware{
+		tokenFunc: func(r *http.Request) string {
+			return r.FormValue("g-recaptcha-response")
+		},
+		userIPFunc: func(r *http.Request) string {
+			userIP, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				return ""
+			}
+			return userIP
+		},
+		scoreHeader:  DefaultScoreHeader,
+		actionHeader: DefaultActionHeader,
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Del(m.scoreHeader)
+			r.Header.Del(m.actionHeader)
+
+			response, err := client.Fetch(r.Context(), m.tokenFunc(r), m.userIPFunc(r))
+			if err != nil {
+				http.Error(w, "error fetching verification response", http.StatusBadGateway)
+				return
+			}
+			if err := response.Verify(criteria...); err != nil {
+				http.Error(w, "invalid reCAPTCHA", http.StatusForbidden)
+				return
+			}
+
+			r.Header.Set(m.scoreHeader, strconv.FormatFloat(response.Score, 'f', -1, 64))
+			r.Header.Set(m.actionHeader, response.Action)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
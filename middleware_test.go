@@ -0,0 +1,186 @@
+package recaptcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyMiddleware(t *testing.T) {
+	fetch := func(ctx context.Context, token string, userIP string) (Response, error) {
+		switch token {
+		case "proxied":
+			if userIP != "203.0.113.7" {
+				return Response{}, errors.New("unexpected user IP")
+			}
+			return Response{
+				Success: true,
+				Score:   .7,
+				Action:  "login",
+			}, nil
+		case "direct":
+			if userIP != "192.0.2.1" {
+				return Response{}, errors.New("unexpected user IP")
+			}
+			return Response{
+				Success: true,
+				Score:   .7,
+				Action:  "login",
+			}, nil
+		case "valid":
+			return Response{
+				Success: true,
+				Score:   .7,
+				Action:  "login",
+			}, nil
+		case "low":
+			return Response{
+				Success: true,
+				Score:   .3,
+				Action:  "login",
+			}, nil
+		default:
+			return Response{}, errors.New("AAHHH")
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		token           string
+		header          http.Header
+		opts            []MiddlewareOption
+		status          int
+		expectedHeaders http.Header
+	}{
+		{
+			name:   "Valid",
+			token:  "valid",
+			status: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Recaptcha-Score":  {"0.7"},
+				"X-Recaptcha-Action": {"login"},
+			},
+		},
+		{
+			name:  "Valid/Spoofed",
+			token: "valid",
+			header: http.Header{
+				"X-Recaptcha-Score": {"1"},
+			},
+			status: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Recaptcha-Score":  {"0.7"},
+				"X-Recaptcha-Action": {"login"},
+			},
+		},
+		{
+			name:   "Valid/HeaderNames",
+			token:  "valid",
+			opts:   []MiddlewareOption{SetHeaderNames("X-Bot-Score", "")},
+			status: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Bot-Score":        {"0.7"},
+				"X-Recaptcha-Action": {"login"},
+			},
+		},
+		{
+			name: "Valid/TokenFunc",
+			header: http.Header{
+				"X-Recaptcha-Token": {"valid"},
+			},
+			opts: []MiddlewareOption{
+				SetTokenFunc(func(r *http.Request) string {
+					return r.Header.Get("X-Recaptcha-Token")
+				}),
+			},
+			status: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Recaptcha-Token":  {"valid"},
+				"X-Recaptcha-Score":  {"0.7"},
+				"X-Recaptcha-Action": {"login"},
+			},
+		},
+		{
+			name:   "Valid/RemoteAddr",
+			token:  "direct",
+			status: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Recaptcha-Score":  {"0.7"},
+				"X-Recaptcha-Action": {"login"},
+			},
+		},
+		{
+			name:  "Valid/UserIPFunc",
+			token: "proxied",
+			header: http.Header{
+				"X-Forwarded-For": {"203.0.113.7"},
+			},
+			opts: []MiddlewareOption{
+				SetUserIPFunc(func(r *http.Request) string {
+					return r.Header.Get("X-Forwarded-For")
+				}),
+			},
+			status: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Forwarded-For":    {"203.0.113.7"},
+				"X-Recaptcha-Score":  {"0.7"},
+				"X-Recaptcha-Action": {"login"},
+			},
+		},
+		{
+			name:  "Invalid",
+			token: "low",
+			header: http.Header{
+				"X-Recaptcha-Score": {"1"},
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "FetchError",
+			token:  "error",
+			status: http.StatusBadGateway,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actualHeaders http.Header
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actualHeaders = http.Header{}
+				for name, values := range r.Header {
+					if name != "Content-Type" {
+						actualHeaders[name] = values
+					}
+				}
+			})
+			client := &Mock{
+				FetchStub: fetch,
+			}
+			handler := VerifyMiddleware(client, []Criterion{Score(.5)}, testCase.opts...)(next)
+
+			form := url.Values{}
+			if testCase.token != "" {
+				form.Set("g-recaptcha-response", testCase.token)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for name, values := range testCase.header {
+				req.Header[name] = values
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != testCase.status {
+				t.Errorf("Expected status %d, got %d", testCase.status, rec.Code)
+			}
+			if !reflect.DeepEqual(testCase.expectedHeaders, actualHeaders) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expectedHeaders, actualHeaders)
+			}
+		})
+	}
+}
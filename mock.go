@@ -3,13 +3,22 @@ package recaptcha
 import (
 	"context"
 	"sync/atomic"
+	"time"
 )
 
 // Mock implements the Client interface, with a stubbed Fetch method for use in
 // testing.
 type Mock struct {
-	FetchStub   func(ctx context.Context, token string, userIP string) (Response, error)
-	FetchCalled int32
+	FetchStub          func(ctx context.Context, token string, userIP string) (Response, error)
+	FetchCalled        int32
+	VerifyCachedStub   func(ctx context.Context, token, userIP string, maxAge time.Duration, criteria ...Criterion) (Response, error)
+	VerifyCachedCalled int32
+	WithStub           func(opts ...Option) Client
+	WithCalled         int32
+	StatsStub          func() Stats
+	StatsCalled        int32
+	ValidateStub       func(ctx context.Context) error
+	ValidateCalled     int32
 }
 
 var _ Client = &Mock{}
@@ -19,3 +28,29 @@ func (m *Mock) Fetch(ctx context.Context, token string, userIP string) (Response
 	atomic.AddInt32(&m.FetchCalled, 1)
 	return m.FetchStub(ctx, token, userIP)
 }
+
+// VerifyCached calls VerifyCachedStub with the provided parameters and returns
+// the result.
+func (m *Mock) VerifyCached(ctx context.Context, token, userIP string, maxAge time.Duration, criteria ...Criterion) (Response, error) {
+	atomic.AddInt32(&m.VerifyCachedCalled, 1)
+	return m.VerifyCachedStub(ctx, token, userIP, maxAge, criteria...)
+}
+
+// With calls WithStub with the provided parameters and returns the result.
+func (m *Mock) With(opts ...Option) Client {
+	atomic.AddInt32(&m.WithCalled, 1)
+	return m.WithStub(opts...)
+}
+
+// Stats calls StatsStub and returns the result.
+func (m *Mock) Stats() Stats {
+	atomic.AddInt32(&m.StatsCalled, 1)
+	return m.StatsStub()
+}
+
+// Validate calls ValidateStub with the provided parameters and returns the
+// result.
+func (m *Mock) Validate(ctx context.Context) error {
+	atomic.AddInt32(&m.ValidateCalled, 1)
+	return m.ValidateStub(ctx)
+}
@@ -0,0 +1,145 @@
+package recaptcha
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// observation holds the arguments of a single Observer invocation.
+type observation struct {
+	ctx context.Context
+	r   Response
+	err error
+}
+
+// AsyncObserver decouples an Observer from the request path by queueing
+// observations in a buffer and passing them to the wrapped Observer on a
+// background goroutine. When the buffer is full, observations are dropped
+// rather than blocking the caller. Use its Observe method with SetObserver,
+// and call Close once the Client is no longer in use.
+//
+// Because observations are handled after Fetch returns, the context passed to
+// the wrapped Observer may already have been cancelled; it should only be
+// used to retrieve request-scoped values.
+type AsyncObserver struct {
+	inner        Observer
+	observations chan observation
+	done         chan struct{}
+	dropped      uint64
+
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewAsyncObserver returns an AsyncObserver which queues up to bufSize
+// observations for the provided Observer.
+func NewAsyncObserver(inner Observer, bufSize int) *AsyncObserver {
+	if bufSize < 0 {
+		bufSize = 0
+	}
+	a := &AsyncObserver{
+		inner:        inner,
+		observations: make(chan observation, bufSize),
+		done:         make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncObserver) run() {
+	defer close(a.done)
+	for o := range a.observations {
+		a.inner(o.ctx, o.r, o.err)
+	}
+}
+
+// Observe queues an observation for the wrapped Observer. It never blocks; if
+// the buffer is full, or the AsyncObserver has been closed, the observation is
+// dropped. Observe has the signature of an Observer, so it can be passed to
+// SetObserver.
+func (a *AsyncObserver) Observe(ctx context.Context, r Response, err error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		atomic.AddUint64(&a.dropped, 1)
+		return
+	}
+	select {
+	case a.observations <- observation{ctx: ctx, r: r, err: err}:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+	}
+}
+
+// Dropped returns the number of observations which have been dropped because
+// the buffer was full or the AsyncObserver had been closed.
+func (a *AsyncObserver) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Close stops accepting observations and waits until all queued observations
+// have been passed to the wrapped Observer. It is safe to call Close more than
+// once.
+func (a *AsyncObserver) Close() {
+	a.closeOnce.Do(func() {
+		a.mu.Lock()
+		a.closed = true
+		close(a.observations)
+		a.mu.Unlock()
+	})
+	<-a.done
+}
+
+// SampledObserver returns an Observer which passes a random sample of
+// observations, in the proportion given by rate (between 0 and 1), to the
+// provided Observer, e.g. to reduce log volume at high request rates. A rate
+// of 1 or more passes every observation, and a rate of 0 or less passes none.
+// The sample is drawn from a securely-seeded random source; use
+// SampledObserverWithSource to provide a deterministic source instead.
+func SampledObserver(rate float64, inner Observer) Observer {
+	return SampledObserverWithSource(rate, inner, newLockedSource().Float64)
+}
+
+// SampledObserverWithSource is like SampledObserver, but draws the sample from
+// the provided source, which must return values in [0, 1) (e.g. the Float64
+// method of a *rand.Rand). This allows the sample to be reproduced in tests by
+// providing a fixed seed. The source must be safe for concurrent use if the
+// returned Observer is called concurrently.
+func SampledObserverWithSource(rate float64, inner Observer, source func() float64) Observer {
+	return func(ctx context.Context, r Response, err error) {
+		if rate >= 1 || (rate > 0 && source() < rate) {
+			inner(ctx, r, err)
+		}
+	}
+}
+
+// lockedSource is a *rand.Rand which is safe for concurrent use.
+type lockedSource struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// newLockedSource returns a lockedSource seeded from crypto/rand, falling back
+// to the current time if crypto/rand is unavailable.
+func newLockedSource() *lockedSource {
+	var seed int64
+	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+		seed = time.Now().UnixNano()
+	}
+	return &lockedSource{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Float64 returns a pseudo-random number in [0, 1).
+func (s *lockedSource) Float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64()
+}
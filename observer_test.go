@@ -0,0 +1,251 @@
+package recaptcha
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAsyncObserverFlush(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		observed []Response
+	)
+	observer := NewAsyncObserver(func(ctx context.Context, r Response, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, r)
+	}, 10)
+
+	expected := []Response{
+		{Action: "a"},
+		{Action: "b"},
+		{Action: "c"},
+	}
+	for _, r := range expected {
+		observer.Observe(context.Background(), r, nil)
+	}
+	observer.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(expected, observed) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, observed)
+	}
+	if dropped := observer.Dropped(); dropped != 0 {
+		t.Errorf("Expected no dropped observations, got %d", dropped)
+	}
+}
+
+func TestAsyncObserverOverflow(t *testing.T) {
+	var (
+		started  = make(chan struct{}, 1)
+		release  = make(chan struct{})
+		mu       sync.Mutex
+		observed int
+	)
+	observer := NewAsyncObserver(func(ctx context.Context, r Response, err error) {
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		observed++
+	}, 1)
+
+	// The first observation is picked up by the background goroutine, which
+	// blocks until released, the second fills the buffer, and the third is
+	// dropped.
+	observer.Observe(context.Background(), Response{}, nil)
+	<-started
+	observer.Observe(context.Background(), Response{}, nil)
+	observer.Observe(context.Background(), Response{}, nil)
+	if dropped := observer.Dropped(); dropped != 1 {
+		t.Errorf("Expected 1 dropped observation, got %d", dropped)
+	}
+
+	close(release)
+	observer.Close()
+
+	// Observations made after Close are dropped.
+	observer.Observe(context.Background(), Response{}, nil)
+	if dropped := observer.Dropped(); dropped != 2 {
+		t.Errorf("Expected 2 dropped observations, got %d", dropped)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if observed != 2 {
+		t.Errorf("Expected 2 observations, got %d", observed)
+	}
+}
+
+func TestAsyncObserverClient(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		observed int
+	)
+	observer := NewAsyncObserver(func(ctx context.Context, r Response, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed++
+	}, 10)
+	client := NewClient("secret",
+		SetObserver(observer.Observe),
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"success": true}`)),
+				}, nil
+			},
+		}),
+	)
+	for i := 0; i < 3; i++ {
+		client.Fetch(context.Background(), "token", "")
+	}
+	observer.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if observed != 3 {
+		t.Errorf("Expected 3 observations, got %d", observed)
+	}
+}
+
+func TestSampledObserver(t *testing.T) {
+	const calls = 10000
+
+	testCases := []struct {
+		name string
+		rate float64
+		min  int64
+		max  int64
+	}{
+		{
+			name: "None",
+			rate: 0,
+			min:  0,
+			max:  0,
+		},
+		{
+			name: "Negative",
+			rate: -1,
+			min:  0,
+			max:  0,
+		},
+		{
+			name: "Quarter",
+			rate: .25,
+			min:  2200,
+			max:  2800,
+		},
+		{
+			name: "All",
+			rate: 1,
+			min:  calls,
+			max:  calls,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var observed int64
+			observer := SampledObserver(testCase.rate, func(ctx context.Context, r Response, err error) {
+				atomic.AddInt64(&observed, 1)
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < calls/4; j++ {
+						observer(context.Background(), Response{}, nil)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if observed < testCase.min || observed > testCase.max {
+				t.Errorf("Expected between %d and %d observations, got %d", testCase.min, testCase.max, observed)
+			}
+		})
+	}
+}
+
+func TestSampledObserverWithSource(t *testing.T) {
+	sample := func(seed int64) []int {
+		var sampled []int
+		index := 0
+		observer := SampledObserverWithSource(.5, func(ctx context.Context, r Response, err error) {
+			sampled = append(sampled, index)
+		}, rand.New(rand.NewSource(seed)).Float64)
+		for ; index < 100; index++ {
+			observer(context.Background(), Response{}, nil)
+		}
+		return sampled
+	}
+
+	expected := sample(42)
+	if len(expected) == 0 || len(expected) == 100 {
+		t.Fatalf("Expected a partial sample, got %d observations", len(expected))
+	}
+	if actual := sample(42); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+	}
+}
+
+func TestSampledObserverWithSourceBounds(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rate     float64
+		value    float64
+		expected bool
+	}{
+		{
+			name:     "Below",
+			rate:     .5,
+			value:    .49,
+			expected: true,
+		},
+		{
+			name:     "Equal",
+			rate:     .5,
+			value:    .5,
+			expected: false,
+		},
+		{
+			name:     "Zero",
+			rate:     0,
+			value:    0,
+			expected: false,
+		},
+		{
+			name:     "One",
+			rate:     1,
+			value:    .99,
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			observed := false
+			observer := SampledObserverWithSource(testCase.rate, func(ctx context.Context, r Response, err error) {
+				observed = true
+			}, func() float64 {
+				return testCase.value
+			})
+			observer(context.Background(), Response{}, nil)
+			if observed != testCase.expected {
+				t.Errorf("Expected observed: %t, got %t", testCase.expected, observed)
+			}
+		})
+	}
+}
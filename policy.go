@@ -0,0 +1,211 @@
+package recaptcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// PolicyConfig is a declarative description of a set of verification criteria,
+// which can be loaded from JSON configuration, e.g.:
+//
+//	{
+//	    "hostnames": ["niche.com"],
+//	    "actions": ["login"],
+//	    "min_score": 0.5,
+//	    "window": "1m"
+//	}
+//
+// The config is validated when it is unmarshalled. Use the Criteria method to
+// obtain the corresponding criteria, which can then be passed to Verify.
+type PolicyConfig struct {
+	// Hostnames, if non-empty, are passed to the Hostname criterion.
+	Hostnames []string
+	// Actions, if non-empty, are passed to the Action criterion.
+	Actions []string
+	// MinScore, if non-zero, is passed to the Score criterion.
+	MinScore float64
+	// Window, if non-zero, is passed to the ChallengeTs criterion.
+	Window time.Duration
+}
+
+// policyConfigJSON is the JSON representation of a PolicyConfig.
+type policyConfigJSON struct {
+	Hostnames []string `json:"hostnames,omitempty"`
+	Actions   []string `json:"actions,omitempty"`
+	MinScore  float64  `json:"min_score,omitempty"`
+	Window    string   `json:"window,omitempty"`
+}
+
+// Validate checks that the config is valid, i.e. that the minimum score is
+// between 0 and 1, and that the window is not negative.
+func (p PolicyConfig) Validate() error {
+	if p.MinScore < 0 || p.MinScore > 1 {
+		return xerrors.Errorf("invalid min_score %v: must be between 0 and 1", p.MinScore)
+	}
+	if p.Window < 0 {
+		return xerrors.Errorf("invalid window %s: must not be negative", p.Window)
+	}
+	return nil
+}
+
+// Criteria returns the verification criteria described by the config.
+func (p PolicyConfig) Criteria() []Criterion {
+	var criteria []Criterion
+	if len(p.Hostnames) > 0 {
+		criteria = append(criteria, Hostname(p.Hostnames...))
+	}
+	if len(p.Actions) > 0 {
+		criteria = append(criteria, Action(p.Actions...))
+	}
+	if p.MinScore != 0 {
+		criteria = append(criteria, Score(p.MinScore))
+	}
+	if p.Window != 0 {
+		criteria = append(criteria, ChallengeTs(p.Window))
+	}
+	return criteria
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (p PolicyConfig) MarshalJSON() ([]byte, error) {
+	config := policyConfigJSON{
+		Hostnames: p.Hostnames,
+		Actions:   p.Actions,
+		MinScore:  p.MinScore,
+	}
+	if p.Window != 0 {
+		config.Window = p.Window.String()
+	}
+	return json.Marshal(config)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The window is
+// parsed via time.ParseDuration, and the resulting config is validated.
+// Unknown keys are rejected, so that a misspelled key (e.g. "min_scor") cannot
+// silently drop a criterion from the policy.
+func (p *PolicyConfig) UnmarshalJSON(data []byte) error {
+	var config policyConfigJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return xerrors.Errorf("error decoding policy config: %w", err)
+	}
+
+	var window time.Duration
+	if config.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(config.Window); err != nil {
+			return xerrors.Errorf("error parsing window: %w", err)
+		}
+	}
+
+	policy := PolicyConfig{
+		Hostnames: config.Hostnames,
+		Actions:   config.Actions,
+		MinScore:  config.MinScore,
+		Window:    window,
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// Policy is a named, reusable set of verification criteria, e.g. for a
+// particular flow (such as "login" or "checkout"), which saves passing the same
+// criteria to Verify throughout a codebase. Policies can be composed by adding
+// one policy to another via Include. A Policy should be fully built before it
+// is used, since it is not safe to add criteria while verifying concurrently.
+type Policy struct {
+	name     string
+	criteria []Criterion
+	included []*Policy
+}
+
+// NewPolicy creates a Policy with the provided name and criteria.
+func NewPolicy(name string, criteria ...Criterion) *Policy {
+	return (&Policy{
+		name: name,
+	}).Add(criteria...)
+}
+
+// Name returns the policy's name.
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// Add appends the provided criteria to the policy, and returns the policy, so
+// that calls can be chained.
+func (p *Policy) Add(criteria ...Criterion) *Policy {
+	p.criteria = append(p.criteria, criteria...)
+	return p
+}
+
+// Include appends the criteria of the provided policies to the policy, as
+// single criteria (see Criterion), and returns the policy, so that calls can be
+// chained. Since the included policies are applied when the policy is
+// verified, criteria added to them later also apply to the policy. A policy
+// which would include itself, directly or via other included policies, is not
+// included, since verifying it would recurse without limit. Instead, a
+// criterion which always returns an error is added in its place.
+func (p *Policy) Include(policies ...*Policy) *Policy {
+	for _, policy := range policies {
+		if policy.includes(p) {
+			err := xerrors.Errorf("policy %q cannot include policy %q, which includes it", p.name, policy.name)
+			p.criteria = append(p.criteria, func(r *Response) error {
+				return err
+			})
+			continue
+		}
+		p.included = append(p.included, policy)
+		p.criteria = append(p.criteria, policy.Criterion())
+	}
+	return p
+}
+
+// includes reports whether the policy is target, or includes it, directly or
+// transitively.
+func (p *Policy) includes(target *Policy) bool {
+	if p == target {
+		return true
+	}
+	for _, policy := range p.included {
+		if policy.includes(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Criteria returns a copy of the policy's criteria, in the order they were
+// added, e.g. for passing to VerifyLenient or Inspect.
+func (p *Policy) Criteria() []Criterion {
+	criteria := make([]Criterion, len(p.criteria))
+	copy(criteria, p.criteria)
+	return criteria
+}
+
+// Criterion returns a Criterion which applies each of the policy's criteria in
+// order, returning the first error encountered, so that the policy can be used
+// wherever a Criterion is accepted (e.g. alongside other criteria in a call to
+// Verify).
+func (p *Policy) Criterion() Criterion {
+	return func(r *Response) error {
+		for _, criterion := range p.criteria {
+			if err := criterion(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Verify verifies the response using the policy's criteria, as in
+// Response.Verify.
+func (p *Policy) Verify(r Response) error {
+	return r.Verify(p.criteria...)
+}
@@ -0,0 +1,346 @@
+package recaptcha
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyConfigJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		json     string
+		expected PolicyConfig
+		err      bool
+	}{
+		{
+			name: "Empty",
+			json: `{}`,
+		},
+		{
+			name: "Full",
+			json: `{"hostnames":["niche.com"],"actions":["login","register"],"min_score":0.5,"window":"1m0s"}`,
+			expected: PolicyConfig{
+				Hostnames: []string{"niche.com"},
+				Actions:   []string{"login", "register"},
+				MinScore:  .5,
+				Window:    time.Minute,
+			},
+		},
+		{
+			name: "UnknownKey",
+			json: `{"hostnames":["niche.com"],"min_scor":0.5}`,
+			err:  true,
+		},
+		{
+			name: "InvalidScore",
+			json: `{"min_score":1.5}`,
+			err:  true,
+		},
+		{
+			name: "InvalidWindow",
+			json: `{"window":"forever"}`,
+			err:  true,
+		},
+		{
+			name: "NegativeWindow",
+			json: `{"window":"-1m"}`,
+			err:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var actual PolicyConfig
+			err := json.Unmarshal([]byte(testCase.json), &actual)
+			if testCase.err {
+				if err == nil {
+					t.Errorf("Expected error, got config:\n%#v\n", actual)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+
+			// Round trip
+			out, err := json.Marshal(actual)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if string(out) != testCase.json {
+				t.Errorf("Expected JSON:\n%s\nActual:\n%s\n", testCase.json, out)
+			}
+		})
+	}
+}
+
+func TestPolicyConfigCriteria(t *testing.T) {
+	// Mock time.Now() function for sake of ChallengeTs tests
+	current := time.Now()
+	now = func() time.Time {
+		return current
+	}
+
+	config := PolicyConfig{
+		Hostnames: []string{"niche.com"},
+		Actions:   []string{"login"},
+		MinScore:  .5,
+		Window:    time.Minute,
+	}
+	criteria := config.Criteria()
+	if len(criteria) != 4 {
+		t.Fatalf("Expected 4 criteria, got %d", len(criteria))
+	}
+
+	testCases := []struct {
+		name     string
+		response Response
+		expected error
+	}{
+		{
+			name: "Success",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+			},
+		},
+		{
+			name: "InvalidHostnameError",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "nathanjcochran.com",
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "nathanjcochran.com",
+			},
+		},
+		{
+			name: "InvalidActionError",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "register",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+			},
+			expected: &InvalidActionError{
+				Action: "register",
+			},
+		},
+		{
+			name: "InvalidScoreError",
+			response: Response{
+				Success:     true,
+				Score:       .4,
+				Action:      "login",
+				ChallengeTs: now().Add(-time.Second),
+				Hostname:    "niche.com",
+			},
+			expected: &InvalidScoreError{
+				Score:     .4,
+				Threshold: .5,
+			},
+		},
+		{
+			name: "InvalidChallengeTsError",
+			response: Response{
+				Success:     true,
+				Score:       .5,
+				Action:      "login",
+				ChallengeTs: now().Add(-2 * time.Minute),
+				Hostname:    "niche.com",
+			},
+			expected: &InvalidChallengeTsError{
+				ChallengeTs: now().Add(-2 * time.Minute),
+				Diff:        2 * time.Minute,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.response.Verify(criteria...)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	base := NewPolicy("base", Hostname("niche.com"))
+	login := NewPolicy("login").
+		Include(base).
+		Add(Action("login"), Score(.5))
+
+	testCases := []struct {
+		name     string
+		policy   *Policy
+		response Response
+		expected error
+	}{
+		{
+			name:   "Base/Pass",
+			policy: base,
+			response: Response{
+				Success:  true,
+				Hostname: "niche.com",
+			},
+			expected: nil,
+		},
+		{
+			name:   "Composed/Pass",
+			policy: login,
+			response: Response{
+				Success:  true,
+				Hostname: "niche.com",
+				Action:   "login",
+				Score:    .9,
+			},
+			expected: nil,
+		},
+		{
+			name:   "Composed/IncludedFails",
+			policy: login,
+			response: Response{
+				Success:  true,
+				Hostname: "example.com",
+				Action:   "login",
+				Score:    .9,
+			},
+			expected: &InvalidHostnameError{
+				Hostname: "example.com",
+			},
+		},
+		{
+			name:   "Composed/AddedFails",
+			policy: login,
+			response: Response{
+				Success:  true,
+				Hostname: "niche.com",
+				Action:   "login",
+				Score:    .1,
+			},
+			expected: &InvalidScoreError{
+				Score:     .1,
+				Threshold: .5,
+			},
+		},
+		{
+			name:   "Unsuccessful",
+			policy: login,
+			response: Response{
+				Success:    false,
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+			expected: &VerificationError{
+				ErrorCodes: []string{"timeout-or-duplicate"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.policy.Verify(testCase.response)
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPolicyIncludeLateAddition(t *testing.T) {
+	base := NewPolicy("base")
+	login := NewPolicy("login").Include(base)
+
+	// Criteria added to an included policy also apply to the including policy
+	base.Add(Action("login"))
+
+	response := Response{
+		Success: true,
+		Action:  "signup",
+	}
+	expected := &InvalidActionError{
+		Action: "signup",
+	}
+	if actual := login.Verify(response); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+	}
+	if name := login.Name(); name != "login" {
+		t.Errorf("Expected name %q, got %q", "login", name)
+	}
+	if criteria := login.Criteria(); len(criteria) != 1 {
+		t.Errorf("Expected 1 criterion, got %d", len(criteria))
+	}
+}
+
+func TestPolicyIncludeCycle(t *testing.T) {
+	testCases := []struct {
+		name  string
+		build func() *Policy
+	}{
+		{
+			name: "Self",
+			build: func() *Policy {
+				p := NewPolicy("p")
+				return p.Include(p)
+			},
+		},
+		{
+			name: "Mutual",
+			build: func() *Policy {
+				a, b := NewPolicy("a"), NewPolicy("b")
+				a.Include(b)
+				b.Include(a)
+				return a
+			},
+		},
+		{
+			name: "Transitive",
+			build: func() *Policy {
+				a, b, c := NewPolicy("a"), NewPolicy("b"), NewPolicy("c")
+				a.Include(b)
+				b.Include(c)
+				c.Include(a)
+				return a
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := Response{
+				Success: true,
+			}
+			if err := testCase.build().Verify(response); err == nil {
+				t.Errorf("Expected error")
+			}
+		})
+	}
+}
+
+func TestPolicyIncludeDiamond(t *testing.T) {
+	// Including the same policy via several paths is not a cycle
+	base := NewPolicy("base", Action("login"))
+	a := NewPolicy("a").Include(base)
+	b := NewPolicy("b").Include(base)
+	top := NewPolicy("top").Include(a, b)
+
+	response := Response{
+		Success: true,
+		Action:  "login",
+	}
+	if err := top.Verify(response); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
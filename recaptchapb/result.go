@@ -0,0 +1,345 @@
+// Package recaptchapb encodes reCAPTCHA verification results in the protocol
+// buffers wire format, for passing between services over gRPC or other
+// protobuf-based transports. The schema is documented in result.proto. The
+// encoding is written by hand, so neither this package nor the recaptcha
+// package depends on a protobuf runtime:
+//
+//	response, err := client.Fetch(ctx, token, userIP)
+//	if err != nil {
+//		return err
+//	}
+//	result := recaptchapb.NewResult(response, response.Verify(criteria...))
+//	b := result.ToProtoBytes()
+package recaptchapb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nicheinc/recaptcha"
+	"golang.org/x/xerrors"
+)
+
+// Field numbers of the Result message, as defined in result.proto.
+const (
+	fieldSuccess     = 1
+	fieldScore       = 2
+	fieldAction      = 3
+	fieldChallengeTs = 4
+	fieldHostname    = 5
+	fieldErrorCodes  = 6
+	fieldExtra       = 7
+	fieldValid       = 8
+	fieldError       = 9
+	fieldPresent     = 10
+)
+
+// Field numbers of the google.protobuf.Timestamp message and of map entries.
+const (
+	fieldTimestampSeconds = 1
+	fieldTimestampNanos   = 2
+	fieldMapKey           = 1
+	fieldMapValue         = 2
+)
+
+// Protocol buffers wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Result is a reCAPTCHA verification response along with the verdict reached
+// by verifying it.
+type Result struct {
+	Response recaptcha.Response
+	// Valid is whether the response passed verification.
+	Valid bool
+	// Error is the message of the verification error, if any.
+	Error string
+}
+
+// NewResult returns a Result for the provided response and the error returned
+// by verifying it (e.g. via its Verify method).
+func NewResult(response recaptcha.Response, verifyErr error) Result {
+	result := Result{
+		Response: response,
+		Valid:    verifyErr == nil,
+	}
+	if verifyErr != nil {
+		result.Error = verifyErr.Error()
+	}
+	return result
+}
+
+// ToProtoBytes encodes the result in the protocol buffers wire format. Fields
+// with zero values are omitted, except for the score, which is included
+// whenever the response has one (see recaptcha.Response.HasScore).
+func (r Result) ToProtoBytes() []byte {
+	var b []byte
+	if r.Response.Success {
+		b = appendBool(b, fieldSuccess, true)
+	}
+	if r.Response.HasScore() {
+		b = appendTag(b, fieldScore, wireFixed64)
+		b = appendFixed64(b, math.Float64bits(r.Response.Score))
+	}
+	if r.Response.Action != "" {
+		b = appendBytes(b, fieldAction, []byte(r.Response.Action))
+	}
+	if ts := r.Response.ChallengeTs; !ts.IsZero() {
+		var msg []byte
+		if seconds := ts.Unix(); seconds != 0 {
+			msg = appendTag(msg, fieldTimestampSeconds, wireVarint)
+			msg = appendVarint(msg, uint64(seconds))
+		}
+		if nanos := ts.Nanosecond(); nanos != 0 {
+			msg = appendTag(msg, fieldTimestampNanos, wireVarint)
+			msg = appendVarint(msg, uint64(nanos))
+		}
+		b = appendBytes(b, fieldChallengeTs, msg)
+	}
+	if r.Response.Hostname != "" {
+		b = appendBytes(b, fieldHostname, []byte(r.Response.Hostname))
+	}
+	for _, code := range r.Response.ErrorCodes {
+		b = appendBytes(b, fieldErrorCodes, []byte(code))
+	}
+	// Map entries are sorted by key, so that the encoding is deterministic.
+	keys := make([]string, 0, len(r.Response.Extra))
+	for key := range r.Response.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry []byte
+		entry = appendBytes(entry, fieldMapKey, []byte(key))
+		entry = appendBytes(entry, fieldMapValue, r.Response.Extra[key])
+		b = appendBytes(b, fieldExtra, entry)
+	}
+	if r.Valid {
+		b = appendBool(b, fieldValid, true)
+	}
+	if r.Error != "" {
+		b = appendBytes(b, fieldError, []byte(r.Error))
+	}
+	for _, name := range r.Response.PresentFields() {
+		b = appendBytes(b, fieldPresent, []byte(name))
+	}
+	return b
+}
+
+// FromProtoBytes decodes a Result encoded in the protocol buffers wire format,
+// e.g. by ToProtoBytes. Unknown fields are ignored.
+func FromProtoBytes(b []byte) (Result, error) {
+	var (
+		result      Result
+		fields      = map[string]interface{}{}
+		errorCodes  []string
+		extra       = map[string]json.RawMessage{}
+		challengeTs time.Time
+		present     []string
+	)
+	err := decodeFields(b, func(field int, wireType int, value uint64, data []byte) error {
+		switch {
+		case field == fieldSuccess && wireType == wireVarint:
+			fields["success"] = value != 0
+		case field == fieldScore && wireType == wireFixed64:
+			fields["score"] = math.Float64frombits(value)
+		case field == fieldAction && wireType == wireBytes:
+			fields["action"] = string(data)
+		case field == fieldChallengeTs && wireType == wireBytes:
+			ts, err := decodeTimestamp(data)
+			if err != nil {
+				return xerrors.Errorf("error decoding challenge_ts: %w", err)
+			}
+			challengeTs = ts
+		case field == fieldHostname && wireType == wireBytes:
+			fields["hostname"] = string(data)
+		case field == fieldErrorCodes && wireType == wireBytes:
+			errorCodes = append(errorCodes, string(data))
+		case field == fieldExtra && wireType == wireBytes:
+			key, value, err := decodeMapEntry(data)
+			if err != nil {
+				return xerrors.Errorf("error decoding extra: %w", err)
+			}
+			extra[key] = value
+		case field == fieldValid && wireType == wireVarint:
+			result.Valid = value != 0
+		case field == fieldError && wireType == wireBytes:
+			result.Error = string(data)
+		case field == fieldPresent && wireType == wireBytes:
+			present = append(present, string(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	// The Response is rebuilt from its JSON representation, so that it is
+	// decoded exactly as if it had been returned by the verification endpoint
+	// (including which fields were present, and any extra fields).
+	if errorCodes != nil {
+		fields["error-codes"] = errorCodes
+	}
+	if !challengeTs.IsZero() {
+		fields["challenge_ts"] = challengeTs
+	}
+	if present != nil {
+		// Fields which were present but omitted from the encoding because
+		// they had zero values are restored, and others are dropped.
+		sent := fields
+		fields = map[string]interface{}{}
+		for _, name := range present {
+			if value, ok := sent[name]; ok {
+				fields[name] = value
+			} else if value, ok := zeroValues[name]; ok {
+				fields[name] = value
+			}
+		}
+	}
+	for key, value := range extra {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return Result{}, xerrors.Errorf("error encoding response: %w", err)
+	}
+	if err := json.Unmarshal(body, &result.Response); err != nil {
+		return Result{}, xerrors.Errorf("error decoding response: %w", err)
+	}
+	return result, nil
+}
+
+// zeroValues are the JSON values of the standard response fields which are
+// omitted from the encoding when zero.
+var zeroValues = map[string]interface{}{
+	"success":      false,
+	"score":        0.0,
+	"action":       "",
+	"challenge_ts": time.Time{},
+	"hostname":     "",
+	"error-codes":  []string{},
+}
+
+// decodeTimestamp decodes a google.protobuf.Timestamp message.
+func decodeTimestamp(b []byte) (time.Time, error) {
+	var seconds, nanos int64
+	err := decodeFields(b, func(field int, wireType int, value uint64, data []byte) error {
+		switch {
+		case field == fieldTimestampSeconds && wireType == wireVarint:
+			seconds = int64(value)
+		case field == fieldTimestampNanos && wireType == wireVarint:
+			nanos = int64(int32(value))
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, nanos).UTC(), nil
+}
+
+// decodeMapEntry decodes a map<string, bytes> entry message.
+func decodeMapEntry(b []byte) (key string, value []byte, err error) {
+	err = decodeFields(b, func(field int, wireType int, v uint64, data []byte) error {
+		switch {
+		case field == fieldMapKey && wireType == wireBytes:
+			key = string(data)
+		case field == fieldMapValue && wireType == wireBytes:
+			value = append([]byte(nil), data...)
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+// decodeFields calls fn with each field of the encoded message b. For varint
+// and fixed-width fields, the value is provided; for length-delimited fields,
+// the data is provided.
+func decodeFields(b []byte, fn func(field int, wireType int, value uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return xerrors.New("malformed field tag")
+		}
+		b = b[n:]
+		field, wireType := int(tag>>3), int(tag&7)
+
+		var (
+			value uint64
+			data  []byte
+		)
+		switch wireType {
+		case wireVarint:
+			value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return xerrors.Errorf("malformed varint in field %d", field)
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return xerrors.Errorf("truncated fixed64 in field %d", field)
+			}
+			value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return xerrors.Errorf("truncated fixed32 in field %d", field)
+			}
+			value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return xerrors.Errorf("truncated bytes in field %d", field)
+			}
+			data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return xerrors.Errorf("unsupported wire type %d in field %d", wireType, field)
+		}
+
+		if err := fn(field, wireType, value, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendBool(b []byte, field int, v bool) []byte {
+	b = appendTag(b, field, wireVarint)
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,178 @@
+package recaptchapb
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nicheinc/recaptcha"
+)
+
+// parseResponse decodes a Response from JSON, as returned by the verification
+// endpoint, so that unexported fields (e.g. score presence) are populated.
+func parseResponse(t *testing.T, body string) recaptcha.Response {
+	t.Helper()
+	var response recaptcha.Response
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return response
+}
+
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name   string
+		result Result
+	}{
+		{
+			name:   "Empty",
+			result: Result{},
+		},
+		{
+			name: "Valid",
+			result: NewResult(parseResponse(t, `{
+				"success": true,
+				"score": 0.9,
+				"action": "login",
+				"challenge_ts": "2019-08-25T16:20:00.123Z",
+				"hostname": "niche.com",
+				"tenant_id": "niche",
+				"region": {"id":1}
+			}`), nil),
+		},
+		{
+			name: "ZeroScore",
+			result: NewResult(parseResponse(t, `{
+				"success": true,
+				"score": 0.0,
+				"action": "login"
+			}`), nil),
+		},
+		{
+			name: "NoScore",
+			result: NewResult(parseResponse(t, `{
+				"success": true,
+				"hostname": "niche.com"
+			}`), nil),
+		},
+		{
+			name: "Invalid",
+			result: NewResult(parseResponse(t, `{
+				"success": false,
+				"error-codes": ["timeout-or-duplicate", "bad-request"]
+			}`), errors.New("invalid reCAPTCHA: timeout-or-duplicate,bad-request")),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := FromProtoBytes(testCase.result.ToProtoBytes())
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(testCase.result, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.result, actual)
+			}
+			if testCase.result.Response.HasScore() != actual.Response.HasScore() {
+				t.Errorf("Expected HasScore %t, got %t", testCase.result.Response.HasScore(), actual.Response.HasScore())
+			}
+		})
+	}
+}
+
+func TestRoundTripPresentFields(t *testing.T) {
+	response := parseResponse(t, `{
+		"success": false,
+		"score": 0.0,
+		"action": "",
+		"error-codes": []
+	}`)
+	actual, err := FromProtoBytes(NewResult(response, nil).ToProtoBytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []string{"success", "score", "action", "error-codes"}
+	if fields := actual.Response.PresentFields(); !reflect.DeepEqual(expected, fields) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, fields)
+	}
+}
+
+func TestToProtoBytes(t *testing.T) {
+	result := NewResult(recaptcha.Response{
+		Success:     true,
+		Action:      "a",
+		ChallengeTs: time.Unix(1, 0),
+	}, nil)
+	expected := []byte{
+		0x08, 0x01, // success: true
+		0x1a, 0x01, 'a', // action: "a"
+		0x22, 0x02, 0x08, 0x01, // challenge_ts: {seconds: 1}
+		0x40, 0x01, // valid: true
+	}
+	if actual := result.ToProtoBytes(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+	}
+}
+
+func TestFromProtoBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		b        []byte
+		expected Result
+		err      bool
+	}{
+		{
+			name: "UnknownFields",
+			b: []byte{
+				0x08, 0x01, // success: true
+				0x50, 0x2a, // field 10 (varint): 42
+				0x5d, 0x00, 0x00, 0x00, 0x00, // field 11 (fixed32): 0
+				0x40, 0x01, // valid: true
+			},
+			expected: Result{
+				Response: parseResponse(t, `{"success": true}`),
+				Valid:    true,
+			},
+		},
+		{
+			name: "TruncatedBytes",
+			b:    []byte{0x1a, 0x05, 'a'},
+			err:  true,
+		},
+		{
+			name: "TruncatedFixed64",
+			b:    []byte{0x11, 0x00},
+			err:  true,
+		},
+		{
+			name: "MalformedVarint",
+			b:    []byte{0x08, 0x80},
+			err:  true,
+		},
+		{
+			name: "UnsupportedWireType",
+			b:    []byte{0x0b},
+			err:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := FromProtoBytes(testCase.b)
+			if testCase.err {
+				if err == nil {
+					t.Errorf("Expected error, got %#v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package recaptchatest provides helpers for testing code which uses the
+// recaptcha package. In particular, it provides assertions for each of the
+// recaptcha package's error types, which hide the boilerplate of extracting the
+// error (which may be wrapped) and comparing its fields. It also provides
+// FixtureTransport, which replays recorded responses from the verification
+// endpoint.
+package recaptchatest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nicheinc/recaptcha"
+	"golang.org/x/xerrors"
+)
+
+// AssertVerificationError asserts that err is (or wraps) a
+// *recaptcha.VerificationError with the provided error codes.
+func AssertVerificationError(t testing.TB, err error, errorCodes ...string) {
+	t.Helper()
+	var target *recaptcha.VerificationError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.VerificationError, got %#v", err)
+	} else if !equalStrings(errorCodes, target.ErrorCodes) {
+		t.Errorf("Expected error codes %q, got %q", errorCodes, target.ErrorCodes)
+	}
+}
+
+// AssertInvalidHostname asserts that err is (or wraps) a
+// *recaptcha.InvalidHostnameError with the provided hostname.
+func AssertInvalidHostname(t testing.TB, err error, hostname string) {
+	t.Helper()
+	var target *recaptcha.InvalidHostnameError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.InvalidHostnameError, got %#v", err)
+	} else if target.Hostname != hostname {
+		t.Errorf("Expected hostname %q, got %q", hostname, target.Hostname)
+	}
+}
+
+// AssertMissingHostname asserts that err is (or wraps) a
+// *recaptcha.MissingHostnameError with the provided source.
+func AssertMissingHostname(t testing.TB, err error, source string) {
+	t.Helper()
+	var target *recaptcha.MissingHostnameError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.MissingHostnameError, got %#v", err)
+	} else if target.Source != source {
+		t.Errorf("Expected source %q, got %q", source, target.Source)
+	}
+}
+
+// AssertHostnameResolution asserts that err is (or wraps) a
+// *recaptcha.HostnameResolutionError with the provided hostname.
+func AssertHostnameResolution(t testing.TB, err error, hostname string) {
+	t.Helper()
+	var target *recaptcha.HostnameResolutionError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.HostnameResolutionError, got %#v", err)
+	} else if target.Hostname != hostname {
+		t.Errorf("Expected hostname %q, got %q", hostname, target.Hostname)
+	}
+}
+
+// AssertInvalidAction asserts that err is (or wraps) a
+// *recaptcha.InvalidActionError with the provided action.
+func AssertInvalidAction(t testing.TB, err error, action string) {
+	t.Helper()
+	var target *recaptcha.InvalidActionError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.InvalidActionError, got %#v", err)
+	} else if target.Action != action {
+		t.Errorf("Expected action %q, got %q", action, target.Action)
+	}
+}
+
+// AssertInvalidScore asserts that err is (or wraps) a
+// *recaptcha.InvalidScoreError with the provided score.
+func AssertInvalidScore(t testing.TB, err error, score float64) {
+	t.Helper()
+	var target *recaptcha.InvalidScoreError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.InvalidScoreError, got %#v", err)
+	} else if target.Score != score {
+		t.Errorf("Expected score %v, got %v", score, target.Score)
+	}
+}
+
+// AssertInvalidChallengeTs asserts that err is (or wraps) a
+// *recaptcha.InvalidChallengeTsError with the provided challenge timestamp.
+func AssertInvalidChallengeTs(t testing.TB, err error, challengeTs time.Time) {
+	t.Helper()
+	var target *recaptcha.InvalidChallengeTsError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.InvalidChallengeTsError, got %#v", err)
+	} else if !target.ChallengeTs.Equal(challengeTs) {
+		t.Errorf("Expected challenge timestamp %s, got %s", challengeTs, target.ChallengeTs)
+	}
+}
+
+// AssertHTTPStatus asserts that err is (or wraps) a *recaptcha.HTTPStatusError
+// with the provided status code.
+func AssertHTTPStatus(t testing.TB, err error, statusCode int) {
+	t.Helper()
+	var target *recaptcha.HTTPStatusError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.HTTPStatusError, got %#v", err)
+	} else if target.StatusCode != statusCode {
+		t.Errorf("Expected status code %d, got %d", statusCode, target.StatusCode)
+	}
+}
+
+// AssertEndpointNotFound asserts that err is (or wraps) a
+// *recaptcha.EndpointNotFoundError with the provided URL.
+func AssertEndpointNotFound(t testing.TB, err error, url string) {
+	t.Helper()
+	var target *recaptcha.EndpointNotFoundError
+	if !xerrors.As(err, &target) {
+		t.Errorf("Expected *recaptcha.EndpointNotFoundError, got %#v", err)
+	} else if target.URL != url {
+		t.Errorf("Expected URL %q, got %q", url, target.URL)
+	}
+}
+
+// equalStrings reports whether a and b contain the same strings, treating nil
+// and empty slices as equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+package recaptchatest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nicheinc/recaptcha"
+	"golang.org/x/xerrors"
+)
+
+// tbMock records the failures reported by the assertions under test.
+type tbMock struct {
+	testing.TB
+	errors []string
+}
+
+func (m *tbMock) Helper() {}
+
+func (m *tbMock) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssert(t *testing.T) {
+	challengeTs := time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		assert func(t testing.TB)
+		fail   bool
+	}{
+		{
+			name: "VerificationError/Pass",
+			assert: func(t testing.TB) {
+				AssertVerificationError(t, &recaptcha.VerificationError{
+					ErrorCodes: []string{"bad-request"},
+				}, "bad-request")
+			},
+		},
+		{
+			name: "VerificationError/Pass/NoCodes",
+			assert: func(t testing.TB) {
+				AssertVerificationError(t, &recaptcha.VerificationError{})
+			},
+		},
+		{
+			name: "VerificationError/Fail/Codes",
+			assert: func(t testing.TB) {
+				AssertVerificationError(t, &recaptcha.VerificationError{
+					ErrorCodes: []string{"bad-request"},
+				}, "timeout-or-duplicate")
+			},
+			fail: true,
+		},
+		{
+			name: "VerificationError/Fail/Type",
+			assert: func(t testing.TB) {
+				AssertVerificationError(t, errors.New("AAHHH"))
+			},
+			fail: true,
+		},
+		{
+			name: "InvalidHostname/Pass",
+			assert: func(t testing.TB) {
+				AssertInvalidHostname(t, &recaptcha.InvalidHostnameError{
+					Hostname: "niche.com",
+				}, "niche.com")
+			},
+		},
+		{
+			name: "InvalidHostname/Fail",
+			assert: func(t testing.TB) {
+				AssertInvalidHostname(t, &recaptcha.InvalidHostnameError{
+					Hostname: "nathanjcochran.com",
+				}, "niche.com")
+			},
+			fail: true,
+		},
+		{
+			name: "MissingHostname/Pass",
+			assert: func(t testing.TB) {
+				AssertMissingHostname(t, &recaptcha.MissingHostnameError{
+					Source: "TLS server name",
+				}, "TLS server name")
+			},
+		},
+		{
+			name: "MissingHostname/Fail",
+			assert: func(t testing.TB) {
+				AssertMissingHostname(t, nil, "TLS server name")
+			},
+			fail: true,
+		},
+		{
+			name: "HostnameResolution/Pass",
+			assert: func(t testing.TB) {
+				AssertHostnameResolution(t, &recaptcha.HostnameResolutionError{
+					Hostname: "niche.com",
+					Err:      errors.New("no such host"),
+				}, "niche.com")
+			},
+		},
+		{
+			name: "HostnameResolution/Fail",
+			assert: func(t testing.TB) {
+				AssertHostnameResolution(t, &recaptcha.InvalidHostnameError{
+					Hostname: "niche.com",
+				}, "niche.com")
+			},
+			fail: true,
+		},
+		{
+			name: "InvalidAction/Pass",
+			assert: func(t testing.TB) {
+				AssertInvalidAction(t, &recaptcha.InvalidActionError{
+					Action: "login",
+				}, "login")
+			},
+		},
+		{
+			name: "InvalidAction/Fail",
+			assert: func(t testing.TB) {
+				AssertInvalidAction(t, &recaptcha.InvalidActionError{
+					Action: "register",
+				}, "login")
+			},
+			fail: true,
+		},
+		{
+			name: "InvalidScore/Pass",
+			assert: func(t testing.TB) {
+				AssertInvalidScore(t, &recaptcha.InvalidScoreError{
+					Score: .4,
+				}, .4)
+			},
+		},
+		{
+			name: "InvalidScore/Pass/Wrapped",
+			assert: func(t testing.TB) {
+				AssertInvalidScore(t, xerrors.Errorf("wrapped: %w", &recaptcha.InvalidScoreError{
+					Score: .4,
+				}), .4)
+			},
+		},
+		{
+			name: "InvalidScore/Fail",
+			assert: func(t testing.TB) {
+				AssertInvalidScore(t, &recaptcha.InvalidScoreError{
+					Score: .3,
+				}, .4)
+			},
+			fail: true,
+		},
+		{
+			name: "InvalidChallengeTs/Pass",
+			assert: func(t testing.TB) {
+				AssertInvalidChallengeTs(t, &recaptcha.InvalidChallengeTsError{
+					ChallengeTs: challengeTs,
+				}, challengeTs)
+			},
+		},
+		{
+			name: "InvalidChallengeTs/Fail",
+			assert: func(t testing.TB) {
+				AssertInvalidChallengeTs(t, &recaptcha.InvalidChallengeTsError{
+					ChallengeTs: challengeTs,
+				}, challengeTs.Add(time.Second))
+			},
+			fail: true,
+		},
+		{
+			name: "HTTPStatus/Pass",
+			assert: func(t testing.TB) {
+				AssertHTTPStatus(t, &recaptcha.HTTPStatusError{
+					StatusCode: 500,
+				}, 500)
+			},
+		},
+		{
+			name: "HTTPStatus/Fail",
+			assert: func(t testing.TB) {
+				AssertHTTPStatus(t, &recaptcha.HTTPStatusError{
+					StatusCode: 502,
+				}, 500)
+			},
+			fail: true,
+		},
+		{
+			name: "EndpointNotFound/Pass",
+			assert: func(t testing.TB) {
+				AssertEndpointNotFound(t, &recaptcha.EndpointNotFoundError{
+					URL: recaptcha.DefaultURL,
+				}, recaptcha.DefaultURL)
+			},
+		},
+		{
+			name: "EndpointNotFound/Fail",
+			assert: func(t testing.TB) {
+				AssertEndpointNotFound(t, &recaptcha.EndpointNotFoundError{
+					URL: "url",
+				}, recaptcha.DefaultURL)
+			},
+			fail: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mock := &tbMock{}
+			testCase.assert(mock)
+			if failed := len(mock.errors) > 0; failed != testCase.fail {
+				t.Errorf("Expected failure: %t, got errors: %q", testCase.fail, mock.errors)
+			}
+		})
+	}
+}
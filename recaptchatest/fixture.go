@@ -0,0 +1,107 @@
+package recaptchatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/xerrors"
+)
+
+// unknownTokenBody is the response body returned by FixtureTransport for
+// tokens without a fixture, which matches the verification endpoint's response
+// for an invalid token.
+const unknownTokenBody = `{"success": false, "error-codes": ["invalid-input-response"]}`
+
+// FixtureTransport is an http.RoundTripper which replays recorded responses
+// from the reCAPTCHA verification endpoint (e.g. captured from a staging
+// environment), keyed by the token in the request. It can be used with the
+// recaptcha.SetHTTPClient option via its Client method, for tests with higher
+// fidelity than hand-written stubs:
+//
+//	transport, err := recaptchatest.LoadFixtures("testdata/fixtures.json")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client := recaptcha.NewClient("secret", recaptcha.SetHTTPClient(transport.Client()))
+//
+// Requests for tokens without a fixture receive the response the verification
+// endpoint gives for an invalid token.
+type FixtureTransport struct {
+	// Fixtures maps each token to the raw JSON response body returned for it.
+	Fixtures map[string]json.RawMessage
+	// TokenField is the name of the form field containing the token. If
+	// empty, "response" is used, matching the recaptcha package's default
+	// (see recaptcha.SetFieldNames).
+	TokenField string
+}
+
+// LoadFixtures returns a FixtureTransport which replays the fixtures in the
+// JSON file at the provided path. The file must contain a JSON object mapping
+// each token to the response body to return for it, e.g.:
+//
+//	{
+//		"valid-token": {"success": true, "score": 0.9, "action": "login"},
+//		"used-token": {"success": false, "error-codes": ["timeout-or-duplicate"]}
+//	}
+func LoadFixtures(path string) (*FixtureTransport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("error reading fixtures: %w", err)
+	}
+	var fixtures map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, xerrors.Errorf("error decoding fixtures from %s: %w", path, err)
+	}
+	return &FixtureTransport{
+		Fixtures: fixtures,
+	}, nil
+}
+
+// Client returns an *http.Client which uses the FixtureTransport, suitable for
+// passing to the recaptcha.SetHTTPClient option.
+func (t *FixtureTransport) Client() *http.Client {
+	return &http.Client{
+		Transport: t,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface, responding to the
+// request with the fixture for its token.
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		defer req.Body.Close()
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, xerrors.Errorf("error reading request body: %w", err)
+		}
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, xerrors.Errorf("error parsing request body: %w", err)
+	}
+
+	tokenField := t.TokenField
+	if tokenField == "" {
+		tokenField = "response"
+	}
+	fixture, ok := t.Fixtures[values.Get(tokenField)]
+	if !ok {
+		fixture = json.RawMessage(unknownTokenBody)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(fixture)),
+		ContentLength: int64(len(fixture)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package recaptchatest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nicheinc/recaptcha"
+)
+
+func TestFixtureTransport(t *testing.T) {
+	transport, err := LoadFixtures("testdata/fixtures.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name   string
+		token  string
+		action string
+		assert func(t testing.TB, err error)
+	}{
+		{
+			name:   "Valid",
+			token:  "valid-token",
+			action: "login",
+			assert: func(t testing.TB, err error) {
+				if err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				}
+			},
+		},
+		{
+			name:   "Used",
+			token:  "used-token",
+			action: "login",
+			assert: func(t testing.TB, err error) {
+				AssertVerificationError(t, err, "timeout-or-duplicate")
+			},
+		},
+		{
+			name:   "Unknown",
+			token:  "unknown-token",
+			action: "login",
+			assert: func(t testing.TB, err error) {
+				AssertVerificationError(t, err, "invalid-input-response")
+			},
+		},
+		{
+			name:   "WrongAction",
+			token:  "valid-token",
+			action: "signup",
+			assert: func(t testing.TB, err error) {
+				AssertInvalidAction(t, err, "login")
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := recaptcha.NewClient("secret", recaptcha.SetHTTPClient(transport.Client()))
+			response, err := client.Fetch(context.Background(), testCase.token, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			testCase.assert(t, response.Verify(
+				recaptcha.Hostname("niche.com"),
+				recaptcha.Action(testCase.action),
+				recaptcha.Score(.5),
+			))
+		})
+	}
+}
+
+func TestFixtureTransportTokenField(t *testing.T) {
+	transport, err := LoadFixtures("testdata/fixtures.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	transport.TokenField = "token"
+
+	client := recaptcha.NewClient("secret",
+		recaptcha.SetHTTPClient(transport.Client()),
+		recaptcha.SetFieldNames("", "token", ""),
+	)
+	response, err := client.Fetch(context.Background(), "valid-token", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !response.Success || response.Score != .9 {
+		t.Errorf("Expected fixture response, got %#v", response)
+	}
+}
+
+func TestLoadFixturesError(t *testing.T) {
+	if _, err := LoadFixtures("testdata/missing.json"); err == nil {
+		t.Errorf("Expected error loading missing fixtures")
+	}
+}
@@ -0,0 +1,122 @@
+package recaptcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// remotePolicyTimeout is the maximum duration of a request to the remote
+// policy service made by the RemotePolicy criterion.
+const remotePolicyTimeout = 5 * time.Second
+
+// remotePolicyMaxBytes is the maximum size of a remote policy service's
+// response body. Larger bodies are truncated, and so fail to decode.
+const remotePolicyMaxBytes = 64 << 10
+
+// remotePolicyVerdict is the JSON response body expected from a remote policy
+// service.
+type remotePolicyVerdict struct {
+	Allow  *bool  `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// RemotePolicy is an optional verification criterion which delegates the
+// decision of whether the response is acceptable to a remote policy service,
+// for centralized policy management. The response is POSTed to the provided
+// URL as a JSON object in the form produced by ToMap (including any extra
+// fields), and the service must respond with a 2xx status code and a JSON
+// object of the form:
+//
+//	{"allow": false, "reason": "score below regional threshold"}
+//
+// The request is made using the provided HTTPClient and context, and times out
+// after 5 seconds if the context has no earlier deadline. Since the context is
+// captured when the criterion is created, prefer RemotePolicyCtx with
+// VerifyContext, which uses the context of the verification instead. If the
+// service cannot be reached, or responds with an error status or a malformed
+// verdict, the response is accepted if failOpen is true and rejected
+// otherwise. Since it makes a network request, this criterion should be
+// provided after any cheaper ones. Returns *RemotePolicyDeniedError if the
+// service denies the response, or *RemotePolicyError if the service fails and
+// failOpen is false.
+func RemotePolicy(ctx context.Context, url string, httpClient HTTPClient, failOpen bool) Criterion {
+	criterion := RemotePolicyCtx(url, httpClient, failOpen)
+	return func(r *Response) error {
+		return criterion(ctx, r)
+	}
+}
+
+// RemotePolicyCtx is like RemotePolicy, but makes the request with the context
+// passed to VerifyContext, so that it is cancelled along with the
+// verification. The response is never accepted due to failOpen once that
+// context is done: *RemotePolicyError is returned instead.
+func RemotePolicyCtx(url string, httpClient HTTPClient, failOpen bool) CriterionCtx {
+	return func(ctx context.Context, r *Response) error {
+		verdict, err := fetchVerdict(ctx, url, httpClient, r)
+		if err != nil {
+			if failOpen && ctx.Err() == nil {
+				return nil
+			}
+			return &RemotePolicyError{
+				URL: url,
+				Err: err,
+			}
+		}
+		if !*verdict.Allow {
+			return &RemotePolicyDeniedError{
+				Reason: verdict.Reason,
+			}
+		}
+		return nil
+	}
+}
+
+// fetchVerdict requests the remote policy service's verdict on the response.
+func fetchVerdict(ctx context.Context, url string, httpClient HTTPClient, r *Response) (remotePolicyVerdict, error) {
+	body, err := json.Marshal(r.ToMap())
+	if err != nil {
+		return remotePolicyVerdict{}, xerrors.Errorf("error marshalling response: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remotePolicyTimeout)
+	defer cancel()
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return remotePolicyVerdict{}, xerrors.Errorf("error creating POST request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request = request.WithContext(ctx)
+
+	res, err := httpClient.Do(request)
+	if err != nil {
+		return remotePolicyVerdict{}, xerrors.Errorf("error making POST request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return remotePolicyVerdict{}, xerrors.Errorf("error making POST request: %w", &HTTPStatusError{
+			StatusCode: res.StatusCode,
+		})
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, remotePolicyMaxBytes))
+	if err != nil {
+		return remotePolicyVerdict{}, xerrors.Errorf("error reading response body: %w", err)
+	}
+	var verdict remotePolicyVerdict
+	if err := json.Unmarshal(data, &verdict); err != nil {
+		return remotePolicyVerdict{}, xerrors.Errorf("error unmarshalling response body: %w", err)
+	}
+	if verdict.Allow == nil {
+		return remotePolicyVerdict{}, xerrors.New("response body missing allow field")
+	}
+	return verdict, nil
+}
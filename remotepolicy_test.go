@@ -0,0 +1,188 @@
+package recaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestRemotePolicy(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     int
+		body       string
+		failOpen   bool
+		expected   error
+		serviceErr bool
+	}{
+		{
+			name:     "Allow",
+			status:   http.StatusOK,
+			body:     `{"allow": true}`,
+			expected: nil,
+		},
+		{
+			name:   "Deny",
+			status: http.StatusOK,
+			body:   `{"allow": false, "reason": "score below regional threshold"}`,
+			expected: &RemotePolicyDeniedError{
+				Reason: "score below regional threshold",
+			},
+		},
+		{
+			name:     "Deny/FailOpen",
+			status:   http.StatusOK,
+			body:     `{"allow": false}`,
+			failOpen: true,
+			expected: &RemotePolicyDeniedError{},
+		},
+		{
+			name:       "ServerError/FailClosed",
+			status:     http.StatusInternalServerError,
+			body:       `{"allow": true}`,
+			serviceErr: true,
+		},
+		{
+			name:     "ServerError/FailOpen",
+			status:   http.StatusInternalServerError,
+			body:     `{"allow": false}`,
+			failOpen: true,
+			expected: nil,
+		},
+		{
+			name:       "Malformed/FailClosed",
+			status:     http.StatusOK,
+			body:       `{"allow": "yes"}`,
+			serviceErr: true,
+		},
+		{
+			name:       "MissingVerdict/FailClosed",
+			status:     http.StatusOK,
+			body:       `{}`,
+			serviceErr: true,
+		},
+		{
+			name:     "MissingVerdict/FailOpen",
+			status:   http.StatusOK,
+			body:     `{}`,
+			failOpen: true,
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var received map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body, _ := ioutil.ReadAll(req.Body)
+				json.Unmarshal(body, &received)
+				w.WriteHeader(testCase.status)
+				w.Write([]byte(testCase.body))
+			}))
+			defer server.Close()
+
+			response, err := parseResponse([]byte(`{"success": true, "score": 0.9, "action": "login", "challenge_ts": "2019-08-25T16:20:00Z", "region": "us"}`))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			actual := response.Verify(RemotePolicy(context.Background(), server.URL, server.Client(), testCase.failOpen))
+
+			if testCase.serviceErr {
+				if policyErr, ok := actual.(*RemotePolicyError); !ok {
+					t.Errorf("Expected *RemotePolicyError, got %#v", actual)
+				} else if policyErr.URL != server.URL {
+					t.Errorf("Expected URL %q, got %q", server.URL, policyErr.URL)
+				}
+			} else if !reflect.DeepEqual(testCase.expected, actual) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", testCase.expected, actual)
+			}
+
+			expectedReceived := map[string]interface{}{
+				"success":      true,
+				"score":        .9,
+				"action":       "login",
+				"challenge_ts": "2019-08-25T16:20:00Z",
+				"region":       "us",
+			}
+			if !reflect.DeepEqual(expectedReceived, received) {
+				t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expectedReceived, received)
+			}
+		})
+	}
+}
+
+func TestRemotePolicyUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response := Response{
+		Success: true,
+	}
+	if err := response.Verify(RemotePolicy(ctx, url, http.DefaultClient, true)); err != nil {
+		t.Errorf("Expected fail open, got %#v", err)
+	}
+	if err, ok := response.Verify(RemotePolicy(ctx, url, http.DefaultClient, false)).(*RemotePolicyError); !ok {
+		t.Errorf("Expected *RemotePolicyError, got %#v", err)
+	}
+}
+
+func TestRemotePolicyCtx(t *testing.T) {
+	testCases := []struct {
+		name     string
+		failOpen bool
+	}{
+		{
+			name:     "FailClosed",
+			failOpen: false,
+		},
+		{
+			name:     "FailOpen",
+			failOpen: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			canceled := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				// The server only notices the client going away once the
+				// body has been read.
+				ioutil.ReadAll(req.Body)
+				<-req.Context().Done()
+				close(canceled)
+			}))
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			response := Response{
+				Success: true,
+			}
+			criterion := RemotePolicyCtx(server.URL, server.Client(), testCase.failOpen)
+			if err := response.VerifyContext(ctx, criterion); !xerrors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected error wrapping %v, got %#v", context.DeadlineExceeded, err)
+			}
+			if err, ok := criterion(ctx, &response).(*RemotePolicyError); !ok {
+				t.Errorf("Expected *RemotePolicyError, got %#v", err)
+			}
+
+			select {
+			case <-canceled:
+			case <-time.After(time.Second):
+				t.Errorf("Expected remote policy request to be cancelled")
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package recaptcha
+
+import (
+	"log/slog"
+	"time"
+)
+
+var _ slog.LogValuer = Response{}
+
+// LogValue implements the slog.LogValuer interface, so that a Response is
+// logged as a group of its fields when passed to a *slog.Logger.
+func (r Response) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("success", r.Success),
+		slog.Float64("score", r.Score),
+		slog.String("action", r.Action),
+		slog.String("challenge_ts", r.ChallengeTs.Format(time.RFC3339)),
+		slog.String("hostname", r.Hostname),
+		slog.Any("error_codes", r.ErrorCodes),
+	)
+}
@@ -0,0 +1,39 @@
+//go:build go1.21
+// +build go1.21
+
+package recaptcha
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLogValue(t *testing.T) {
+	response := Response{
+		Success:     true,
+		Score:       .5,
+		Action:      "login",
+		ChallengeTs: time.Date(2019, 8, 25, 16, 20, 0, 0, time.UTC),
+		Hostname:    "niche.com",
+		ErrorCodes:  []string{"timeout-or-duplicate"},
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Info("captcha", "resp", response)
+
+	expected := "level=INFO msg=captcha resp.success=true resp.score=0.5 resp.action=login " +
+		"resp.challenge_ts=2019-08-25T16:20:00Z resp.hostname=niche.com resp.error_codes=[timeout-or-duplicate]\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s\n", expected, actual)
+	}
+}
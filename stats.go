@@ -0,0 +1,85 @@
+package recaptcha
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Stats contains counts of the results of Fetch calls made by a Client, as
+// returned by its Stats method.
+type Stats struct {
+	// Fetches is the total number of calls to Fetch.
+	Fetches uint64
+	// Errors is the number of calls to Fetch that returned an error.
+	Errors uint64
+	// Successes is the number of responses which passed the default
+	// verification check (i.e. "success" was true and "error-codes" was
+	// empty).
+	Successes uint64
+	// Failures is the number of responses which failed the default
+	// verification check.
+	Failures uint64
+}
+
+// stats is a thread-safe counter of Fetch results.
+type stats struct {
+	fetches   uint64
+	errors    uint64
+	successes uint64
+	failures  uint64
+}
+
+// record updates the counters with the result of a Fetch call.
+func (s *stats) record(r Response, err error) {
+	atomic.AddUint64(&s.fetches, 1)
+	switch {
+	case err != nil:
+		atomic.AddUint64(&s.errors, 1)
+	case r.IsSuccess():
+		atomic.AddUint64(&s.successes, 1)
+	default:
+		atomic.AddUint64(&s.failures, 1)
+	}
+}
+
+// snapshot returns the current values of the counters.
+func (s *stats) snapshot() Stats {
+	return Stats{
+		Fetches:   atomic.LoadUint64(&s.fetches),
+		Errors:    atomic.LoadUint64(&s.errors),
+		Successes: atomic.LoadUint64(&s.successes),
+		Failures:  atomic.LoadUint64(&s.failures),
+	}
+}
+
+// MetricsHandler returns an http.Handler which renders the provided Client's
+// Stats in the OpenMetrics text exposition format, which can be scraped by
+// Prometheus and compatible systems.
+func MetricsHandler(client Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := client.Stats()
+		counters := []struct {
+			name  string
+			help  string
+			value uint64
+		}{
+			{"recaptcha_fetches", "Total number of reCAPTCHA token verification requests.", stats.Fetches},
+			{"recaptcha_errors", "Number of reCAPTCHA token verification requests that resulted in an error.", stats.Errors},
+			{"recaptcha_successes", "Number of reCAPTCHA token verification responses that passed verification.", stats.Successes},
+			{"recaptcha_failures", "Number of reCAPTCHA token verification responses that failed verification.", stats.Failures},
+		}
+
+		var b strings.Builder
+		for _, counter := range counters {
+			fmt.Fprintf(&b, "# TYPE %s counter\n", counter.name)
+			fmt.Fprintf(&b, "# HELP %s %s\n", counter.name, counter.help)
+			fmt.Fprintf(&b, "%s_total %d\n", counter.name, counter.value)
+		}
+		b.WriteString("# EOF\n")
+
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		fmt.Fprint(w, b.String())
+	})
+}
@@ -0,0 +1,134 @@
+package recaptcha
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	client := NewClient("secret",
+		SetHTTPClient(&httpClientMock{
+			doStub: func(req *http.Request) (*http.Response, error) {
+				if err := req.ParseForm(); err != nil {
+					return nil, err
+				}
+				var body string
+				switch req.PostForm.Get("response") {
+				case "error":
+					return nil, errors.New("AAHHH")
+				case "valid":
+					body = `{"success": true}`
+				default:
+					body = `{"success": false, "error-codes": ["invalid-input-response"]}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			},
+		}),
+	)
+
+	tokens := map[string]int{
+		"valid":   30,
+		"invalid": 20,
+		"error":   10,
+	}
+	var wg sync.WaitGroup
+	for token, n := range tokens {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(token string) {
+				defer wg.Done()
+				client.Fetch(context.Background(), token, "")
+			}(token)
+		}
+	}
+	wg.Wait()
+
+	expected := Stats{
+		Fetches:   60,
+		Errors:    10,
+		Successes: 30,
+		Failures:  20,
+	}
+	if actual := client.Stats(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected:\n%#v\nActual:\n%#v\n", expected, actual)
+	}
+
+	// Clones start from zero
+	if actual := client.With().Stats(); !reflect.DeepEqual(Stats{}, actual) {
+		t.Errorf("Expected clone stats to be zero, got:\n%#v\n", actual)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	client := &Mock{
+		StatsStub: func() Stats {
+			return Stats{
+				Fetches:   60,
+				Errors:    10,
+				Successes: 30,
+				Failures:  20,
+			}
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(client).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("Expected OpenMetrics content type, got %q", contentType)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if last := lines[len(lines)-1]; last != "# EOF" {
+		t.Errorf("Expected output to end with # EOF, got %q", last)
+	}
+
+	types := map[string]string{}
+	samples := map[string]uint64{}
+	for _, line := range lines[:len(lines)-1] {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 4 && fields[0] == "#" && fields[1] == "TYPE":
+			types[fields[2]] = fields[3]
+		case len(fields) > 2 && fields[0] == "#" && fields[1] == "HELP":
+		case len(fields) == 2:
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				t.Errorf("Error parsing sample %q: %s", line, err)
+			}
+			samples[fields[0]] = value
+		default:
+			t.Errorf("Unexpected line: %q", line)
+		}
+	}
+
+	expectedTypes := map[string]string{
+		"recaptcha_fetches":   "counter",
+		"recaptcha_errors":    "counter",
+		"recaptcha_successes": "counter",
+		"recaptcha_failures":  "counter",
+	}
+	if !reflect.DeepEqual(expectedTypes, types) {
+		t.Errorf("Expected types:\n%#v\nActual:\n%#v\n", expectedTypes, types)
+	}
+	expectedSamples := map[string]uint64{
+		"recaptcha_fetches_total":   60,
+		"recaptcha_errors_total":    10,
+		"recaptcha_successes_total": 30,
+		"recaptcha_failures_total":  20,
+	}
+	if !reflect.DeepEqual(expectedSamples, samples) {
+		t.Errorf("Expected samples:\n%#v\nActual:\n%#v\n", expectedSamples, samples)
+	}
+}